@@ -17,13 +17,24 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // StorageClass represents a storage class that should reference a KubeVirt storage class on infra cluster.
+// +kubebuilder:validation:XValidation:rule="!has(self.allowedTopologies) || (!has(self.zones) && !has(self.regions))",message="allowedTopologies is mutually exclusive with zones and regions"
+// +kubebuilder:validation:XValidation:rule="!has(self.topologyFromNodes) || (!has(self.allowedTopologies) && !has(self.zones) && !has(self.regions))",message="topologyFromNodes is mutually exclusive with allowedTopologies, zones and regions"
+// +kubebuilder:validation:XValidation:rule="!has(self.perZone) || !self.perZone || has(self.zones) || has(self.topologyFromNodes)",message="perZone requires zones or topologyFromNodes"
+// +kubebuilder:validation:XValidation:rule="!has(self.perZone) || !self.perZone || !has(self.isDefaultClass) || !self.isDefaultClass",message="perZone storage classes can't be the default class"
 type StorageClass struct {
+	// Optional: Name of the StorageClass created in the tenant cluster. Defaults to
+	// kubevirt-<InfraStorageClassName>, shortened with a hash suffix if it exceeds 253 characters.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Name string `json:"name,omitempty"`
 	// Name of the storage class to use on the infrastructure cluster.
 	InfraStorageClassName string `json:"infraStorageClassName"`
 	// Optional: IsDefaultClass if true, the created StorageClass will be annotated with:
@@ -45,18 +56,58 @@ type StorageClass struct {
 	// for increased availability
 	Zones []string `json:"zones,omitempty"`
 	// Regions represents a larger domain, made up of one or more zones. It is uncommon for Kubernetes clusters
-	// to span multiple regions
+	// to span multiple regions. If both Zones and Regions are set, volumes are restricted to nodes matching both.
 	Regions []string `json:"regions,omitempty"`
+	// Optional: AllowedTopologies restricts the topology domains where volumes can be provisioned. Terms may use
+	// arbitrary topology keys; requirements within a term are ANDed, the terms are ORed.
+	// Mutually exclusive with Zones and Regions.
+	// +optional
+	AllowedTopologies []corev1.TopologySelectorTerm `json:"allowedTopologies,omitempty"`
+	// Optional: TopologyFromNodes derives the allowed topologies from the zone and region labels of the tenant
+	// cluster nodes and keeps them in sync as nodes are added or removed.
+	// Mutually exclusive with AllowedTopologies, Zones and Regions.
+	// +optional
+	TopologyFromNodes *TopologyFromNodes `json:"topologyFromNodes,omitempty"`
+	// Optional: PerZone creates one StorageClass named <name>-<zone> per zone listed in Zones or discovered by
+	// TopologyFromNodes, each restricted to its zone. StorageClasses of removed zones are deleted. Zones that aren't valid
+	// names are lower cased, invalid characters are replaced with dashes and a hash of the zone is appended.
+	// +optional
+	PerZone bool `json:"perZone,omitempty"`
 	// ReclaimPolicy controls the reclaimPolicy for dynamically provisioned PersistentVolumes of this storage class.
 	// Defaults to Delete.
 	ReclaimPolicy string `json:"reclaimPolicy,omitempty"`
 	// AllowVolumeExpansion shows whether the storage class allow volume expand.
 	AllowVolumeExpansion bool `json:"allowVolumeExpansion,omitempty"`
+	// Optional: Parameters are additional parameters passed to the CSI driver. The keys infraStorageClassName
+	// and bus are reserved and set from the corresponding fields.
+	// +kubebuilder:validation:XValidation:rule="!('infraStorageClassName' in self) && !('bus' in self)",message="parameters must not contain the reserved keys infraStorageClassName and bus"
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Optional: FsType is the filesystem type of the provisioned volumes. It is passed to the CSI driver
+	// as the csi.storage.k8s.io/fstype parameter.
+	// +optional
+	FsType string `json:"fsType,omitempty"`
+	// Optional: MountOptions are the mount options of dynamically provisioned PersistentVolumes, e.g. discard or noatime.
+	// +optional
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
+// TopologyFromNodes configures how allowed topologies are derived from the tenant cluster nodes.
+type TopologyFromNodes struct {
+	// Optional: NodeSelector restricts the nodes whose topology labels are collected. Defaults to all nodes.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
 }
 
 // VolumeSnapshotClass contains a list of KubeVirt infra cluster VolumeSnapshotClasses names used
 // to initialise VolumeSnapshotClasses in the tenant cluster.
 type VolumeSnapshotClass struct {
+	// Optional: Name of the VolumeSnapshotClass created in the tenant cluster. Defaults to
+	// kubevirt-<InfraVolumeSnapshotClass>, shortened with a hash suffix if it exceeds 253 characters.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Name string `json:"name,omitempty"`
 	// InfraVolumeSnapshotClass of the volume snapshot class to use on the infrastructure cluster.
 	InfraVolumeSnapshotClass string `json:"infraVolumeSnapshotClass"`
 	// Optional: IsDefaultClass. If true, the created VolumeSnapshotClass in the tenant cluster will be annotated with:
@@ -66,8 +117,71 @@ type VolumeSnapshotClass struct {
 	IsDefaultClass *bool `json:"isDefaultClass,omitempty"`
 	// Optional: DeletionPolicy defines how the VolumeSnapshotClass should be deleted. Defaults to Delete.
 	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// Optional: Labels added to the created VolumeSnapshotClass. Labels set by others are preserved.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Optional: Annotations added to the created VolumeSnapshotClass. Annotations set by others are preserved.
+	// The default class annotation is set from IsDefaultClass.
+	// +kubebuilder:validation:XValidation:rule="!('snapshot.storage.kubernetes.io/is-default-class' in self)",message="annotations must not contain the reserved key snapshot.storage.kubernetes.io/is-default-class, use isDefaultClass instead"
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Optional: Parameters are additional parameters passed to the CSI driver. The key infraSnapshotClassName
+	// is reserved and set from InfraVolumeSnapshotClass.
+	// +kubebuilder:validation:XValidation:rule="!('infraSnapshotClassName' in self)",message="parameters must not contain the reserved key infraSnapshotClassName"
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
+// VolumeGroupSnapshotClass contains a KubeVirt infra cluster VolumeSnapshotClass name used to initialise a
+// VolumeGroupSnapshotClass in the tenant cluster.
+type VolumeGroupSnapshotClass struct {
+	// Optional: Name of the VolumeGroupSnapshotClass created in the tenant cluster. Defaults to
+	// kubevirt-<InfraVolumeSnapshotClass>, shortened with a hash suffix if it exceeds 253 characters.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// InfraVolumeSnapshotClass of the volume snapshot class to use on the infrastructure cluster.
+	InfraVolumeSnapshotClass string `json:"infraVolumeSnapshotClass"`
+	// Optional: IsDefaultClass. If true, the created VolumeGroupSnapshotClass in the tenant cluster will be annotated with:
+	// groupsnapshot.storage.kubernetes.io/is-default-class: true
+	// If missing or false, annotation will be:
+	// groupsnapshot.storage.kubernetes.io/is-default-class: false
+	IsDefaultClass *bool `json:"isDefaultClass,omitempty"`
+	// Optional: DeletionPolicy defines how the VolumeGroupSnapshotClass should be deleted. Defaults to Delete.
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// VolumeAttributesClass maps a VolumeAttributesClass of the tenant cluster to the infra cluster. Changing the
+// VolumeAttributesClass of a bound PVC modifies the volume parameters of the infra cluster volume.
+type VolumeAttributesClass struct {
+	// Optional: Name of the VolumeAttributesClass created in the tenant cluster. Defaults to
+	// kubevirt-<InfraVolumeAttributesClassName>, shortened with a hash suffix if it exceeds 253 characters.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// InfraVolumeAttributesClassName of the volume attributes class to use on the infrastructure cluster.
+	InfraVolumeAttributesClassName string `json:"infraVolumeAttributesClassName"`
+	// Optional: Parameters are additional parameters passed to the CSI driver. The key infraVolumeAttributesClassName
+	// is reserved and set from the corresponding field.
+	// +kubebuilder:validation:XValidation:rule="!('infraVolumeAttributesClassName' in self)",message="parameters must not contain the reserved key infraVolumeAttributesClassName"
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// DefaultClassPolicy defines how the operator handles default classes that conflict with the default class of the tenant.
+// +kubebuilder:validation:Enum=Report;DemoteOthers
+type DefaultClassPolicy string
+
+const (
+	// DefaultClassPolicyReport only reports conflicting default classes.
+	DefaultClassPolicyReport DefaultClassPolicy = "Report"
+	// DefaultClassPolicyDemoteOthers removes the default class annotation from all other classes if the tenant
+	// defines a default class.
+	DefaultClassPolicyDemoteOthers DefaultClassPolicy = "DemoteOthers"
+)
+
 // TenantSpec defines the desired state of Tenant.
 type TenantSpec struct {
 	// Image repository address
@@ -80,6 +194,41 @@ type TenantSpec struct {
 	// VolumeSnapshotClasses represents volume snapshot classes that the tenant operator should create.
 	// +optional
 	VolumeSnapshotClasses []VolumeSnapshotClass `json:"volumeSnapshotClasses,omitempty"`
+	// Optional: DefaultStorageClassPolicy defines how default StorageClasses of the cluster, including those not
+	// managed by the operator, that conflict with the default StorageClass of the tenant are handled.
+	// Defaults to Report.
+	// +optional
+	DefaultStorageClassPolicy DefaultClassPolicy `json:"defaultStorageClassPolicy,omitempty"`
+	// Optional: DefaultVolumeSnapshotClassPolicy defines how default VolumeSnapshotClasses of the csi.kubevirt.io driver,
+	// including those not managed by the operator, that conflict with the default VolumeSnapshotClass of the tenant
+	// are handled. Defaults to Report.
+	// +optional
+	DefaultVolumeSnapshotClassPolicy DefaultClassPolicy `json:"defaultVolumeSnapshotClassPolicy,omitempty"`
+	// VolumeGroupSnapshotClasses represents volume group snapshot classes that the tenant operator should create.
+	// +optional
+	VolumeGroupSnapshotClasses []VolumeGroupSnapshotClass `json:"volumeGroupSnapshotClasses,omitempty"`
+	// Optional: DefaultVolumeGroupSnapshotClassPolicy defines how default VolumeGroupSnapshotClasses of the
+	// csi.kubevirt.io driver, including those not managed by the operator, that conflict with the default
+	// VolumeGroupSnapshotClass of the tenant are handled. Defaults to Report.
+	// +optional
+	DefaultVolumeGroupSnapshotClassPolicy DefaultClassPolicy `json:"defaultVolumeGroupSnapshotClassPolicy,omitempty"`
+	// VolumeAttributesClasses represents volume attributes classes that the tenant operator should create.
+	// VolumeAttributesClasses created by the operator that are removed from the list are deleted.
+	// +optional
+	VolumeAttributesClasses []VolumeAttributesClass `json:"volumeAttributesClasses,omitempty"`
+	// Optional: CommonLabels are added to every object the operator manages in the tenant cluster. Labels set by
+	// others are preserved, labels configured on individual classes take precedence.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// Optional: CommonAnnotations are added to every object the operator manages in the tenant cluster. Annotations
+	// set by others are preserved, annotations configured on individual classes take precedence.
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// Optional: InstallSnapshotController installs or upgrades the snapshot.storage.k8s.io CRDs and deploys the
+	// snapshot-controller in the tenant cluster. CRDs that were not installed by the operator are left untouched, the
+	// snapshot-controller is not deployed then or if one is already running.
+	// +optional
+	InstallSnapshotController bool `json:"installSnapshotController,omitempty"`
 }
 
 // TenantStatus defines the observed state of Tenant.
@@ -89,8 +238,39 @@ type TenantStatus struct {
 	// +patchMergeKey=resource
 	// +patchStrategy=merge,retainKeys
 	ResourceConditions []ResourceStatusCondition `json:"resourceConditions,omitempty"`
+	// Conditions represent the latest available observations of the tenant state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// ConditionDefaultStorageClassUnique indicates whether at most one StorageClass of the cluster is marked as default.
+	ConditionDefaultStorageClassUnique = "DefaultStorageClassUnique"
+	// ConditionDefaultVolumeSnapshotClassUnique indicates whether at most one VolumeSnapshotClass of the csi.kubevirt.io
+	// driver is marked as default.
+	ConditionDefaultVolumeSnapshotClassUnique = "DefaultVolumeSnapshotClassUnique"
+	// ConditionVolumeSnapshotAPIAvailable indicates whether the snapshot.storage.k8s.io API is installed. VolumeSnapshotClasses
+	// are only reconciled if it is available.
+	ConditionVolumeSnapshotAPIAvailable = "VolumeSnapshotAPIAvailable"
+	// ConditionDefaultVolumeGroupSnapshotClassUnique indicates whether at most one VolumeGroupSnapshotClass of the
+	// csi.kubevirt.io driver is marked as default.
+	ConditionDefaultVolumeGroupSnapshotClassUnique = "DefaultVolumeGroupSnapshotClassUnique"
+	// ConditionVolumeGroupSnapshotAPIAvailable indicates whether the groupsnapshot.storage.k8s.io API is installed.
+	// VolumeGroupSnapshotClasses are only reconciled if it is available.
+	ConditionVolumeGroupSnapshotAPIAvailable = "VolumeGroupSnapshotAPIAvailable"
+	// ConditionVolumeAttributesClassAPIAvailable indicates whether the storage.k8s.io/v1 VolumeAttributesClass API is
+	// served. VolumeAttributesClasses are only reconciled if it is available.
+	ConditionVolumeAttributesClassAPIAvailable = "VolumeAttributesClassAPIAvailable"
+	// ConditionVolumeAttributesClassesReconciled indicates whether the VolumeAttributesClasses of the tenant are
+	// up to date.
+	ConditionVolumeAttributesClassesReconciled = "VolumeAttributesClassesReconciled"
+	// ConditionStorageClassesUpToDate indicates whether the StorageClasses of the tenant match their desired state.
+	// Immutable fields of existing StorageClasses are only changed by recreating classes using topologyFromNodes.
+	ConditionStorageClassesUpToDate = "StorageClassesUpToDate"
+)
+
 // ResourceStatusCondition contains details for the current condition.
 type ResourceStatusCondition struct {
 	// Resource represents a k8s resource that has been created/updated by the operator.
@@ -0,0 +1,176 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// AuditStatus is the result of the audit of the node affinity of a PV.
+type AuditStatus string
+
+const (
+	// AuditMatching is reported for PVs whose node affinity already pins them to their topology.
+	AuditMatching AuditStatus = "Matching"
+	// AuditMissing is reported for PVs whose node affinity lacks requirements on their topology, or that have no node
+	// affinity at all.
+	AuditMissing AuditStatus = "Missing"
+	// AuditPatched is reported for PVs with missing requirements that were added by the audit.
+	AuditPatched AuditStatus = "Patched"
+	// AuditConflicting is reported for PVs whose node affinity contradicts their topology.
+	AuditConflicting AuditStatus = "Conflicting"
+	// AuditRejected is reported for PVs with missing requirements that can't be added, as the API server rejected the
+	// change.
+	AuditRejected AuditStatus = "Rejected"
+	// AuditUnknown is reported for PVs whose topology can't be determined.
+	AuditUnknown AuditStatus = "Unknown"
+)
+
+// AuditResult is the audit of the node affinity of a PV.
+type AuditResult struct {
+	PersistentVolume      string
+	PersistentVolumeClaim types.NamespacedName
+	Status                AuditStatus
+	Message               string
+}
+
+// Audit compares the node affinity of all PVs provisioned by csi.kubevirt.io with the affinity reconcilePVCs would
+// set. Unless dryRun is set, missing requirements are added like the controller does.
+func (r *Reconciler) Audit(ctx context.Context, dryRun bool) ([]AuditResult, error) {
+	pvs := &corev1.PersistentVolumeList{}
+	if err := r.Client.List(ctx, pvs); err != nil {
+		return nil, fmt.Errorf("failed to list pvs: %w", err)
+	}
+
+	var results []AuditResult
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != provisioner || pv.Spec.ClaimRef == nil {
+			continue
+		}
+		result, err := r.auditPersistentVolume(ctx, pv, dryRun)
+		if err != nil {
+			return results, err
+		}
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+	return results, nil
+}
+
+// auditPersistentVolume audits the node affinity of the PV. Nil is returned if the PV isn't bound to a kubevirt PVC.
+func (r *Reconciler) auditPersistentVolume(ctx context.Context, pv *corev1.PersistentVolume, dryRun bool) (*AuditResult, error) {
+	pvcName := types.NamespacedName{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(ctx, pvcName, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pvc %s: %w", pvcName, err)
+	}
+	if pvc.UID != pv.Spec.ClaimRef.UID || pvc.Status.Phase != corev1.ClaimBound || pvc.Spec.VolumeName != pv.Name {
+		return nil, nil
+	}
+
+	sc, isKubevirtVolume, err := r.getKubevirtStorageClass(ctx, pvc, pv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the storage class of pvc %s: %w", pvcName, err)
+	}
+	if !isKubevirtVolume {
+		return nil, nil
+	}
+
+	result := &AuditResult{PersistentVolume: pv.Name, PersistentVolumeClaim: pvcName}
+	topology, err := r.getVolumeTopology(ctx, pvc, pv, sc)
+	if err != nil {
+		return nil, err
+	}
+	if topology.reason != "" {
+		result.Status, result.Message = AuditUnknown, topology.message
+		return result, nil
+	}
+
+	_, changed, err := mergeNodeAffinity(pv.Spec.NodeAffinity, topology.requirements)
+	switch {
+	case err != nil:
+		result.Status = AuditConflicting
+	case !hasNodeAffinity(pv) && len(topology.requirements) == 0:
+		// Without requirements there's nothing to add, the PV is still reported like the missing node affinity metric.
+		result.Status, result.Message = AuditMissing, "node affinity is missing and no topology is known"
+		if topology.source != "" {
+			result.Message = fmt.Sprintf("node affinity is missing and %s has no topology", topology.source)
+		}
+		return result, nil
+	case !changed:
+		result.Status = AuditMatching
+	case dryRun:
+		result.Status = AuditMissing
+	default:
+		// The outcome of the patch is reported, as the PV may have changed in the meantime.
+		result.Status, err = r.patchNodeAffinity(ctx, pvc, pv, topology.requirements, topology.source)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status == "" {
+			return nil, nil
+		}
+	}
+
+	switch result.Status {
+	case AuditConflicting:
+		if _, _, err := mergeNodeAffinity(pv.Spec.NodeAffinity, topology.requirements); err != nil {
+			result.Message = fmt.Sprintf("contradicts the topology of %s: %v", topology.source, err)
+		}
+	case AuditRejected:
+		result.Message = fmt.Sprintf("requirements of %s are missing and were rejected", topology.source)
+	case AuditMissing:
+		result.Message = fmt.Sprintf("requirements of %s are missing", topology.source)
+	case AuditPatched:
+		result.Message = fmt.Sprintf("added the requirements of %s", topology.source)
+	}
+	return result, nil
+}
+
+// backfill adds the missing node affinity of the PVs provisioned before the controller was running. PVCs are also
+// reconciled once the controller starts, the backfill additionally reports the state of all PVs.
+func (r *Reconciler) backfill(ctx context.Context) error {
+	l := log.FromContext(ctx).WithName("backfill")
+	results, err := r.Audit(ctx, false)
+	if err != nil {
+		// A failed backfill must not stop the manager, the controller repairs the PVs anyway.
+		l.Error(err, "failed to backfill the node affinity of PVs")
+		return nil
+	}
+
+	counts := make(map[AuditStatus]int)
+	for _, result := range results {
+		counts[result.Status]++
+		if result.Status == AuditConflicting || result.Status == AuditRejected || result.Status == AuditUnknown {
+			l.Info("Node affinity of PV needs attention", "pv-name", result.PersistentVolume, "pvc-name", result.PersistentVolumeClaim, "status", result.Status, "message", result.Message)
+		}
+	}
+	l.Info("Backfilled the node affinity of PVs", "matching", counts[AuditMatching], "patched", counts[AuditPatched], "missing", counts[AuditMissing],
+		"rejected", counts[AuditRejected], "conflicting", counts[AuditConflicting], "unknown", counts[AuditUnknown])
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Audit", func() {
+	var testReconcile *Reconciler
+	var testClient client.Client
+
+	// createBoundClaim returns a PVC provisioned on node-1 and its PV with the node affinity.
+	createBoundClaim := func(name string, affinity *corev1.VolumeNodeAffinity) (*corev1.PersistentVolumeClaim, *corev1.PersistentVolume) {
+		pvc := createTestClaim(ptr.To("kubevirt"), name, corev1.ClaimBound)
+		pvc.Name = name
+		pvc.UID = types.UID(name)
+		pvc.Annotations = map[string]string{selectedNodeAnnotationKey: "node-1"}
+		pv := createTestVolume(name, provisioner)
+		pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: pvc.Namespace, Name: pvc.Name, UID: pvc.UID}
+		pv.Spec.NodeAffinity = affinity
+		return pvc, pv
+	}
+
+	BeforeEach(func() {
+		objects := []client.Object{
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "kubevirt"}, Provisioner: provisioner},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{corev1.LabelTopologyZone: "a"}}},
+		}
+		for name, affinity := range map[string]*corev1.VolumeNodeAffinity{
+			"missing":     nil,
+			"matching":    createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}),
+			"incomplete":  createTestAffinity([]corev1.NodeSelectorRequirement{{Key: "rack", Operator: corev1.NodeSelectorOpIn, Values: []string{"1"}}}),
+			"conflicting": createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}),
+		} {
+			pvc, pv := createBoundClaim(name, affinity)
+			objects = append(objects, pvc, pv)
+		}
+		// The volume of a node without topology labels can't be pinned.
+		pvc, pv := createBoundClaim("no-topology", nil)
+		pvc.Annotations[selectedNodeAnnotationKey] = "node-2"
+		objects = append(objects, pvc, pv, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}})
+		objects = append(objects, createTestVolume("foreign", "foreign.csi.io"))
+
+		testClient = newIndexedClientBuilder(objects...).Build()
+		testReconcile = &Reconciler{Client: testClient, Recorder: events.NewFakeRecorder(10)}
+	})
+
+	getStatuses := func(results []AuditResult) map[string]AuditStatus {
+		statuses := make(map[string]AuditStatus, len(results))
+		for _, result := range results {
+			statuses[result.PersistentVolume] = result.Status
+		}
+		return statuses
+	}
+
+	It("should only report the node affinity in a dry run", func() {
+		results, err := testReconcile.Audit(context.TODO(), true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getStatuses(results)).Should(Equal(map[string]AuditStatus{
+			"missing":     AuditMissing,
+			"matching":    AuditMatching,
+			"incomplete":  AuditMissing,
+			"no-topology": AuditMissing,
+			"conflicting": AuditConflicting,
+		}))
+		pv := &corev1.PersistentVolume{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "missing"}, pv)).NotTo(HaveOccurred())
+		Expect(pv.Spec.NodeAffinity).Should(BeNil())
+	})
+
+	It("should report the outcome of adding the missing requirements", func() {
+		results, err := testReconcile.Audit(context.TODO(), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getStatuses(results)).Should(Equal(map[string]AuditStatus{
+			"missing":     AuditPatched,
+			"matching":    AuditMatching,
+			"incomplete":  AuditPatched,
+			"no-topology": AuditMissing,
+			"conflicting": AuditConflicting,
+		}))
+		pv := &corev1.PersistentVolume{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "missing"}, pv)).NotTo(HaveOccurred())
+		Expect(pv.Spec.NodeAffinity).Should(Equal(createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")})))
+	})
+})
@@ -18,21 +18,69 @@ package persistentvolumeclaims
 
 import (
 	"context"
+	"errors"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
 	provisioner    = "csi.kubevirt.io"
 	ControllerName = "persistent-volume-claims-controller"
+
+	selectedNodeAnnotationKey = "volume.kubernetes.io/selected-node"
+	// storageClassProvisionerIndex indexes StorageClasses by their provisioner.
+	storageClassProvisionerIndex = "provisioner"
+	// pvcVolumeNameIndex indexes PVCs by the name of their bound PV.
+	pvcVolumeNameIndex = "spec.volumeName"
+	// pvcSelectedNodeIndex indexes PVCs by the node selected by the scheduler.
+	pvcSelectedNodeIndex = "selectedNode"
+	// volumeAttachmentVolumeNameIndex indexes VolumeAttachments by the name of their PV.
+	volumeAttachmentVolumeNameIndex = "spec.source.persistentVolumeName"
+
+	reasonVolumeNotFound  = "VolumeNotFound"
+	reasonNodeNotFound    = "NodeNotFound"
+	reasonNodeAffinitySet = "NodeAffinitySet"
+	// reasonNodeAffinityUpToDate is used if the node affinity of a PV already matches the topology.
+	reasonNodeAffinityUpToDate = "NodeAffinityUpToDate"
+	// reasonNoTopology is used if neither the selected node nor the StorageClass provide topology labels.
+	reasonNoTopology = "NoTopology"
+	// reasonNodeAffinityConflict is used if the node affinity of a PV contradicts the topology of the selected node.
+	reasonNodeAffinityConflict = "NodeAffinityConflict"
+	reasonNodeAffinityRejected = "NodeAffinityRejected"
+	reasonAmbiguousTopology    = "AmbiguousTopology"
+
+	reasonGetClaimFailed             = "GetClaimFailed"
+	reasonGetVolumeFailed            = "GetVolumeFailed"
+	reasonGetNodeFailed              = "GetNodeFailed"
+	reasonGetVolumeAttachmentsFailed = "GetVolumeAttachmentsFailed"
+	reasonPatchClaimFailed           = "PatchClaimFailed"
+	reasonResolveStorageClassFailed  = "ResolveStorageClassFailed"
+	reasonPatchVolumeFailed          = "PatchVolumeFailed"
+	reasonUnknown                    = "Unknown"
 )
 
 type Reconciler struct {
 	client.Client
+	Recorder events.EventRecorder
+	// TopologyKeys are the node labels copied into the node affinity of the PVs, DefaultTopologyKeys if empty.
+	TopologyKeys []string
+
+	// skipped holds the reason the node affinity of a PVC was last left unchanged, so that the event is only recorded
+	// when the reason changes.
+	skipped sync.Map
 }
 
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -40,21 +88,117 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	pvc := &corev1.PersistentVolumeClaim{}
 	if err := r.Client.Get(ctx, req.NamespacedName, pvc); err != nil {
+		if apierrors.IsNotFound(err) {
+			l.V(1).Info("PVC not found, it was deleted", "pvc-name", req.NamespacedName)
+			r.skipped.Delete(req.NamespacedName)
+			return reconcile.Result{}, nil
+		}
 		l.Error(err, "unable to get PVC", "pvc-name", req.NamespacedName)
+		reconcileErrors.WithLabelValues(reasonGetClaimFailed).Inc()
 		return reconcile.Result{}, err
 	}
 
 	if err := r.reconcilePVCs(ctx, pvc); err != nil {
 		l.Error(err, "failed to reconcile PVC", "pvc-name", req.NamespacedName)
+		reason := reasonUnknown
+		var reconcileErr reconcileError
+		if errors.As(err, &reconcileErr) {
+			reason = reconcileErr.reason
+		}
+		reconcileErrors.WithLabelValues(reason).Inc()
 		return reconcile.Result{}, err
 	}
 
 	return reconcile.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// mapPersistentVolumeToPVCs returns the request of the PVC bound to the PV.
+func (r *Reconciler) mapPersistentVolumeToPVCs(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.getPVCRequests(ctx, client.MatchingFields{pvcVolumeNameIndex: obj.GetName()})
+}
+
+// mapNodeToPVCs returns the requests of the PVCs provisioned on the node.
+func (r *Reconciler) mapNodeToPVCs(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.getPVCRequests(ctx, client.MatchingFields{pvcSelectedNodeIndex: obj.GetName()})
+}
+
+// getPVCRequests returns the requests of the kubevirt PVCs matching the fields.
+func (r *Reconciler) getPVCRequests(ctx context.Context, fields client.MatchingFields) []reconcile.Request {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, pvcs, fields); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list PVCs", "fields", fields)
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range pvcs.Items {
+		if r.isKubevirtClaim(ctx, &pvcs.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&pvcs.Items[i])})
+		}
+	}
+	return requests
+}
+
+// isKubevirtVolume reports whether the PV is provisioned by csi.kubevirt.io.
+func isKubevirtVolume(obj client.Object) bool {
+	csi := obj.(*corev1.PersistentVolume).Spec.CSI
+	return csi != nil && csi.Driver == provisioner
+}
+
+// IndexFields registers the field indexes the controller relies on.
+func IndexFields(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &storagev1.StorageClass{}, storageClassProvisionerIndex, func(obj client.Object) []string {
+		return []string{obj.(*storagev1.StorageClass).Provisioner}
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &corev1.PersistentVolumeClaim{}, pvcVolumeNameIndex, func(obj client.Object) []string {
+		if volumeName := obj.(*corev1.PersistentVolumeClaim).Spec.VolumeName; volumeName != "" {
+			return []string{volumeName}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &corev1.PersistentVolumeClaim{}, pvcSelectedNodeIndex, func(obj client.Object) []string {
+		if nodeName := obj.GetAnnotations()[selectedNodeAnnotationKey]; nodeName != "" {
+			return []string{nodeName}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &storagev1.VolumeAttachment{}, volumeAttachmentVolumeNameIndex, func(obj client.Object) []string {
+		if volumeName := obj.(*storagev1.VolumeAttachment).Spec.Source.PersistentVolumeName; volumeName != nil {
+			return []string{*volumeName}
+		}
+		return nil
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager. Besides PVCs, the PVs and the nodes of the PVCs are watched,
+// so that the node affinity is repaired if a PV is changed or the topology labels of a node change.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.Background()
+	if err := IndexFields(ctx, mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	if err := ctrlmetrics.Registry.Register(newMissingNodeAffinityCollector(mgr.GetClient())); err != nil {
+		return err
+	}
+	// The backfill runs once the caches are synced and only on the leader, as it writes PVs.
+	if err := mgr.Add(manager.RunnableFunc(r.backfill)); err != nil {
+		return err
+	}
+
+	kubevirtClaims := predicate.NewTypedPredicateFuncs(func(obj client.Object) bool {
+		return r.isKubevirtClaim(ctx, obj.(*corev1.PersistentVolumeClaim))
+	})
+	kubevirtVolumes := predicate.NewTypedPredicateFuncs(isKubevirtVolume)
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.PersistentVolumeClaim{}).
+		Named(ControllerName).
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(kubevirtClaims)).
+		Watches(&corev1.PersistentVolume{}, handler.EnqueueRequestsFromMapFunc(r.mapPersistentVolumeToPVCs), builder.WithPredicates(kubevirtVolumes)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.mapNodeToPVCs), builder.WithPredicates(predicate.LabelChangedPredicate{})).
 		Complete(r)
 }
@@ -0,0 +1,81 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// builderIndexer registers the field indexes with the fake client builder.
+type builderIndexer struct {
+	*fake.ClientBuilder
+}
+
+func (b builderIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	b.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// newIndexedClientBuilder returns a fake client builder with the objects and the field indexes of the controller.
+func newIndexedClientBuilder(objects ...client.Object) *fake.ClientBuilder {
+	builder := fake.NewClientBuilder().WithObjects(objects...)
+	Expect(IndexFields(context.TODO(), builderIndexer{builder})).NotTo(HaveOccurred())
+	return builder
+}
+
+var _ = Describe("Map funcs", func() {
+	var testReconcile *Reconciler
+
+	createIndexedClaim := func(name, storageClassName, volumeName string) *corev1.PersistentVolumeClaim {
+		pvc := createTestClaim(ptr.To(storageClassName), volumeName, corev1.ClaimBound)
+		pvc.Name = name
+		pvc.Annotations = map[string]string{selectedNodeAnnotationKey: "node-1"}
+		return pvc
+	}
+
+	BeforeEach(func() {
+		testReconcile = &Reconciler{Client: newIndexedClientBuilder(
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "kubevirt"}, Provisioner: provisioner},
+			&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "foreign"}, Provisioner: "foreign.csi.io"},
+			createIndexedClaim("pvc-kubevirt", "kubevirt", "pv-kubevirt"),
+			createIndexedClaim("pvc-foreign", "foreign", "pv-foreign"),
+		).Build()}
+	})
+
+	It("should map a PV to its kubevirt PVC", func() {
+		expected := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "pvc-kubevirt"}}}
+		Expect(testReconcile.mapPersistentVolumeToPVCs(context.TODO(), createTestVolume("pv-kubevirt", provisioner))).Should(Equal(expected))
+		Expect(testReconcile.mapPersistentVolumeToPVCs(context.TODO(), createTestVolume("pv-foreign", "foreign.csi.io"))).Should(BeEmpty())
+	})
+
+	It("should map a node to the kubevirt PVCs provisioned on it", func() {
+		expected := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "pvc-kubevirt"}}}
+		Expect(testReconcile.mapNodeToPVCs(context.TODO(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})).Should(Equal(expected))
+		Expect(testReconcile.mapNodeToPVCs(context.TODO(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}})).Should(BeEmpty())
+	})
+})
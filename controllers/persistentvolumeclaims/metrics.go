@@ -0,0 +1,94 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// listTimeout bounds the time listing the PVs may take when the metrics are scraped.
+const listTimeout = 10 * time.Second
+
+var (
+	// pvNodeAffinityPatches counts the PVs whose node affinity was patched.
+	pvNodeAffinityPatches = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "kubevirt_csi_operator_pv_node_affinity_patches_total",
+			Help: "Number of PVs whose node affinity was patched.",
+		},
+	)
+
+	// reconcileErrors counts the failed reconciliations of PVCs that are retried, by reason. Problems that retrying
+	// doesn't solve are only reported with events.
+	reconcileErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kubevirt_csi_operator_pvc_reconcile_errors_total",
+			Help: "Number of PVC reconciliations that failed and are retried, by reason.",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(pvNodeAffinityPatches, reconcileErrors)
+}
+
+// missingNodeAffinityCollector reports the number of PVs provisioned by csi.kubevirt.io without node affinity. The PVs
+// are counted when the metrics are scraped, so deleted PVs never have to be tracked.
+type missingNodeAffinityCollector struct {
+	reader client.Reader
+	desc   *prometheus.Desc
+}
+
+func newMissingNodeAffinityCollector(reader client.Reader) *missingNodeAffinityCollector {
+	return &missingNodeAffinityCollector{
+		reader: reader,
+		desc: prometheus.NewDesc(
+			"kubevirt_csi_operator_pvs_missing_node_affinity",
+			"Number of PVs provisioned by csi.kubevirt.io without node affinity.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *missingNodeAffinityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *missingNodeAffinityCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	pvs := &corev1.PersistentVolumeList{}
+	if err := c.reader.List(ctx, pvs); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	missing := 0
+	for i := range pvs.Items {
+		if pv := &pvs.Items[i]; isKubevirtVolume(pv) && !hasNodeAffinity(pv) {
+			missing++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(missing))
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Missing node affinity metric", func() {
+	It("should count kubevirt volumes whose node affinity doesn't restrict the nodes", func() {
+		empty := createTestVolume("empty", provisioner)
+		empty.Spec.NodeAffinity = &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{}}
+		pinned := createTestVolume("pinned", provisioner)
+		pinned.Spec.NodeAffinity = createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")})
+
+		collector := newMissingNodeAffinityCollector(fake.NewClientBuilder().WithObjects(
+			createTestVolume("missing", provisioner),
+			empty,
+			pinned,
+			createTestVolume("foreign", "foreign.csi.io"),
+		).Build())
+		Expect(testutil.ToFloat64(collector)).Should(Equal(2.0))
+	})
+})
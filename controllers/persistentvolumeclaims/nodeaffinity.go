@@ -0,0 +1,166 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+// DefaultTopologyKeys are the node labels copied into the node affinity of the PVs if no other keys are configured.
+var DefaultTopologyKeys = []string{"topology.kubernetes.io/zone", "topology.kubernetes.io/region"}
+
+// getTopologyKeys returns the node labels copied into the node affinity of PVs of the StorageClass. Classes restricting
+// the topology of their volumes with allowedTopologies add the keys they restrict to the configured keys.
+func (r *Reconciler) getTopologyKeys(sc *storagev1.StorageClass) []string {
+	keys := slices.Clone(r.getConfiguredTopologyKeys())
+	if sc != nil {
+		for _, term := range sc.AllowedTopologies {
+			for _, expression := range term.MatchLabelExpressions {
+				if !slices.Contains(keys, expression.Key) {
+					keys = append(keys, expression.Key)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// getConfiguredTopologyKeys returns the configured node labels copied into the node affinity of PVs.
+func (r *Reconciler) getConfiguredTopologyKeys() []string {
+	if len(r.TopologyKeys) > 0 {
+		return r.TopologyKeys
+	}
+	return DefaultTopologyKeys
+}
+
+// getNodeAffinityRequirements returns the requirements pinning a volume to the topology of the node.
+func getNodeAffinityRequirements(node *corev1.Node, topologyKeys []string) []corev1.NodeSelectorRequirement {
+	var requirements []corev1.NodeSelectorRequirement
+	for _, key := range topologyKeys {
+		if value := node.Labels[key]; value != "" {
+			requirements = append(requirements, corev1.NodeSelectorRequirement{
+				Key:      key,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{value},
+			})
+		}
+	}
+	return requirements
+}
+
+// getAllowedTopologyRequirements returns the requirements pinning a volume to the allowed topology of the StorageClass.
+// An error is returned if the class allows more than one topology, as the topology of the volume is ambiguous then.
+func getAllowedTopologyRequirements(sc *storagev1.StorageClass) ([]corev1.NodeSelectorRequirement, error) {
+	if len(sc.AllowedTopologies) == 0 {
+		return nil, nil
+	}
+	if len(sc.AllowedTopologies) > 1 {
+		return nil, fmt.Errorf("%d allowed topologies", len(sc.AllowedTopologies))
+	}
+
+	var requirements []corev1.NodeSelectorRequirement
+	for _, expression := range sc.AllowedTopologies[0].MatchLabelExpressions {
+		if len(expression.Values) != 1 {
+			return nil, fmt.Errorf("%s allows %v", expression.Key, expression.Values)
+		}
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      expression.Key,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{expression.Values[0]},
+		})
+	}
+	return requirements, nil
+}
+
+// mergeNodeAffinity adds the requirements missing in the terms of the node affinity and reports whether it changed.
+// Existing requirements and terms are never removed. As the terms are ORed, the requirements are only added to the
+// terms compatible with them, and an error is only returned if every term contradicts one of the requirements, as the
+// volume would then be pinned to a topology other than the one of the selected node.
+func mergeNodeAffinity(affinity *corev1.VolumeNodeAffinity, requirements []corev1.NodeSelectorRequirement) (*corev1.VolumeNodeAffinity, bool, error) {
+	if len(requirements) == 0 {
+		return affinity, false, nil
+	}
+	if affinity == nil || affinity.Required == nil || len(affinity.Required.NodeSelectorTerms) == 0 {
+		return &corev1.VolumeNodeAffinity{
+			Required: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: requirements}},
+			},
+		}, true, nil
+	}
+
+	merged := affinity.DeepCopy()
+	changed := false
+	var conflicts []error
+	for i := range merged.Required.NodeSelectorTerms {
+		term := &merged.Required.NodeSelectorTerms[i]
+		var missing []corev1.NodeSelectorRequirement
+		var conflict error
+		for _, requirement := range requirements {
+			satisfied, err := isRequirementSatisfied(term.MatchExpressions, requirement)
+			if err != nil {
+				conflict = fmt.Errorf("node selector term %d: %w", i, err)
+				break
+			}
+			if !satisfied {
+				missing = append(missing, requirement)
+			}
+		}
+		if conflict != nil {
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		if len(missing) > 0 {
+			term.MatchExpressions = append(term.MatchExpressions, missing...)
+			changed = true
+		}
+	}
+	if len(conflicts) == len(merged.Required.NodeSelectorTerms) {
+		return nil, false, errors.Join(conflicts...)
+	}
+	return merged, changed, nil
+}
+
+// isRequirementSatisfied reports whether the expressions of a term already pin the key of the requirement to its
+// single value, and returns an error if they exclude it.
+func isRequirementSatisfied(expressions []corev1.NodeSelectorRequirement, requirement corev1.NodeSelectorRequirement) (bool, error) {
+	value := requirement.Values[0]
+	satisfied := false
+	for _, expression := range expressions {
+		if expression.Key != requirement.Key {
+			continue
+		}
+		switch expression.Operator {
+		case corev1.NodeSelectorOpIn:
+			if !slices.Contains(expression.Values, value) {
+				return false, fmt.Errorf("%s is restricted to %v, which doesn't contain %s", expression.Key, expression.Values, value)
+			}
+			satisfied = satisfied || len(expression.Values) == 1
+		case corev1.NodeSelectorOpNotIn:
+			if slices.Contains(expression.Values, value) {
+				return false, fmt.Errorf("%s excludes %s", expression.Key, value)
+			}
+		case corev1.NodeSelectorOpDoesNotExist:
+			return false, fmt.Errorf("%s must not exist", expression.Key)
+		}
+	}
+	return satisfied, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+func zoneRequirement(operator corev1.NodeSelectorOperator, zones ...string) corev1.NodeSelectorRequirement {
+	return corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyZone, Operator: operator, Values: zones}
+}
+
+func createTestAffinity(terms ...[]corev1.NodeSelectorRequirement) *corev1.VolumeNodeAffinity {
+	affinity := &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{}}
+	for _, term := range terms {
+		affinity.Required.NodeSelectorTerms = append(affinity.Required.NodeSelectorTerms, corev1.NodeSelectorTerm{MatchExpressions: term})
+	}
+	return affinity
+}
+
+var _ = Describe("Node affinity", func() {
+	regionRequirement := corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyRegion, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu"}}
+
+	DescribeTable("getTopologyKeys",
+		func(configured []string, allowedTopologies []corev1.TopologySelectorTerm, expected []string) {
+			r := &Reconciler{TopologyKeys: configured}
+			Expect(r.getTopologyKeys(&storagev1.StorageClass{AllowedTopologies: allowedTopologies})).Should(Equal(expected))
+		},
+		Entry("defaults", nil, nil, DefaultTopologyKeys),
+		Entry("configured keys", []string{"rack"}, nil, []string{"rack"}),
+		Entry("keys of the allowed topologies added to the configured keys", []string{"rack"}, []corev1.TopologySelectorTerm{
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a"}}}},
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"b"}}, {Key: corev1.LabelTopologyRegion, Values: []string{"eu"}}}},
+		}, []string{"rack", corev1.LabelTopologyZone, corev1.LabelTopologyRegion}),
+		Entry("default keys of a class restricting only zones", nil, []corev1.TopologySelectorTerm{
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a"}}}},
+		}, DefaultTopologyKeys),
+	)
+
+	It("should use the configured keys without storage class", func() {
+		Expect((&Reconciler{TopologyKeys: []string{"rack"}}).getTopologyKeys(nil)).Should(Equal([]string{"rack"}))
+	})
+
+	DescribeTable("getAllowedTopologyRequirements",
+		func(allowedTopologies []corev1.TopologySelectorTerm, expected []corev1.NodeSelectorRequirement, ambiguous bool) {
+			requirements, err := getAllowedTopologyRequirements(&storagev1.StorageClass{AllowedTopologies: allowedTopologies})
+			Expect(err != nil).Should(Equal(ambiguous))
+			Expect(requirements).Should(Equal(expected))
+		},
+		Entry("without allowed topologies", nil, nil, false),
+		Entry("with a single topology", []corev1.TopologySelectorTerm{
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a"}}, {Key: corev1.LabelTopologyRegion, Values: []string{"eu"}}}},
+		}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a"), regionRequirement}, false),
+		Entry("with several terms", []corev1.TopologySelectorTerm{
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a"}}}},
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"b"}}}},
+		}, nil, true),
+		Entry("with several values", []corev1.TopologySelectorTerm{
+			{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a", "b"}}}},
+		}, nil, true),
+	)
+
+	DescribeTable("isRequirementSatisfied",
+		func(expressions []corev1.NodeSelectorRequirement, satisfied, conflict bool) {
+			result, err := isRequirementSatisfied(expressions, zoneRequirement(corev1.NodeSelectorOpIn, "a"))
+			Expect(err != nil).Should(Equal(conflict))
+			Expect(result).Should(Equal(satisfied))
+		},
+		Entry("no expressions", nil, false, false),
+		Entry("other key", []corev1.NodeSelectorRequirement{regionRequirement}, false, false),
+		Entry("pinned to the value", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}, true, false),
+		Entry("allowing more values", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a", "b")}, false, false),
+		Entry("restricted to other values", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}, false, true),
+		Entry("excluding other values", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpNotIn, "b")}, false, false),
+		Entry("excluding the value", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpNotIn, "a")}, false, true),
+		Entry("requiring the key to exist", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpExists)}, false, false),
+		Entry("requiring the key to not exist", []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpDoesNotExist)}, false, true),
+	)
+
+	DescribeTable("mergeNodeAffinity",
+		func(affinity *corev1.VolumeNodeAffinity, requirements []corev1.NodeSelectorRequirement, expected *corev1.VolumeNodeAffinity, changed, conflict bool) {
+			merged, result, err := mergeNodeAffinity(affinity, requirements)
+			if conflict {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).Should(Equal(changed))
+			Expect(merged).Should(Equal(expected))
+		},
+		Entry("without requirements", nil, nil, nil, false, false),
+		Entry("without affinity",
+			nil, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")},
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}), true, false),
+		Entry("with satisfied requirements",
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}), []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")},
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}), false, false),
+		Entry("adding missing requirements to every term",
+			createTestAffinity([]corev1.NodeSelectorRequirement{regionRequirement}, nil), []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")},
+			createTestAffinity([]corev1.NodeSelectorRequirement{regionRequirement, zoneRequirement(corev1.NodeSelectorOpIn, "a")}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}), true, false),
+		Entry("with a contradicting term",
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}), []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")},
+			nil, false, true),
+		Entry("with a term allowing the value",
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}), []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")},
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}), false, false),
+		Entry("adding missing requirements only to compatible terms",
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}), []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b"), regionRequirement},
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b"), regionRequirement}), true, false),
+		Entry("with every term contradicting the requirements",
+			createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}, []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}), []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "c")},
+			nil, false, true),
+	)
+})
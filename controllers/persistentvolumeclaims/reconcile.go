@@ -18,86 +18,265 @@ package persistentvolumeclaims
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// nodeAffinityRejectedAnnotationKey marks PVCs whose PV node affinity can't be set to their topology, its value
+// explains why.
+const nodeAffinityRejectedAnnotationKey = "csiprovisioner.kubevirt.io/node-affinity-rejected"
+
+// volumeTopology is the topology a volume is pinned to with its node affinity.
+type volumeTopology struct {
+	requirements []corev1.NodeSelectorRequirement
+	// source describes where the requirements are taken from.
+	source string
+	// reason and message explain why the topology of the volume is unknown.
+	reason  string
+	message string
+}
+
 func (r *Reconciler) reconcilePVCs(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
-	storageClassName := pvc.Spec.StorageClassName
-	if storageClassName == nil {
-		return fmt.Errorf("storageClassName is nil for pvc %s is empty", pvc.Name)
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return nil
 	}
 
-	sc := storagev1.StorageClass{}
-	if err := r.Get(ctx, client.ObjectKey{Name: *storageClassName}, &sc); err != nil {
-		return fmt.Errorf("failed to get storage class %s: %w", *storageClassName, err)
+	pv := &corev1.PersistentVolume{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, pv); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The PV of a bound PVC is only missing if it was deleted, retrying doesn't bring it back.
+			r.recordWarning(pvc, nil, reasonVolumeNotFound, "Bound volume %s not found", pvc.Spec.VolumeName)
+			return nil
+		}
+		return reconcileError{reasonGetVolumeFailed, fmt.Errorf("failed to get pv %s: %w", pvc.Spec.VolumeName, err)}
 	}
 
-	// if the annotation 'volume.kubernetes.io/selected-node' is not set, the PV node affinity setting should
-	// be ignored as this volume doesn't have a zone/region aware topologies.
-	assignedNodeName := pvc.Annotations["volume.kubernetes.io/selected-node"]
+	// PVCs whose class can't be resolved to csi.kubevirt.io are not handled by this controller.
+	sc, isKubevirtVolume, err := r.getKubevirtStorageClass(ctx, pvc, pv)
+	if err != nil {
+		return reconcileError{reasonResolveStorageClassFailed, fmt.Errorf("failed to resolve the storage class of pvc %s: %w", pvc.Name, err)}
+	}
+	if !isKubevirtVolume {
+		return nil
+	}
 
-	if pvc.Status.Phase == corev1.ClaimBound && sc.Provisioner == provisioner && assignedNodeName != "" {
+	topology, err := r.getVolumeTopology(ctx, pvc, pv, sc)
+	if err != nil {
+		return err
+	}
+	if topology.reason != "" {
+		// Volumes that are already pinned to a topology don't need attention. Otherwise the PVC is marked, so that
+		// the unknown topology is visible on the PVC and only reported once.
+		if !hasNodeAffinity(pv) {
+			marked, err := r.setClaimAnnotation(ctx, pvc, topologyUnknownAnnotationKey, topology.message)
+			if err != nil {
+				return err
+			}
+			if marked {
+				r.recordWarning(pvc, pv, topology.reason, "Node affinity of volume %s can't be set: %s", pv.Name, topology.message)
+			}
+		}
+		return nil
+	}
 
-		pv := &corev1.PersistentVolume{}
-		if err := r.Client.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, pv); err != nil {
-			return err
+	status, err := r.patchNodeAffinity(ctx, pvc, pv, topology.requirements, topology.source)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status != AuditMatching:
+		r.skipped.Delete(client.ObjectKeyFromObject(pvc))
+	case len(topology.requirements) == 0:
+		r.recordSkipped(pvc, pv, reasonNoTopology, "Node affinity of volume %s is left unchanged, no topology labels are known", pv.Name)
+	default:
+		r.recordSkipped(pvc, pv, reasonNodeAffinityUpToDate, "Node affinity of volume %s already matches the topology of %s", pv.Name, topology.source)
+	}
+	_, err = r.setClaimAnnotation(ctx, pvc, topologyUnknownAnnotationKey, "")
+	return err
+}
+
+// getVolumeTopology returns the topology the volume of the PVC is pinned to. The topology is taken from the node
+// selected by the scheduler, or from the allowed topologies of the StorageClass if the volume was bound immediately.
+// If the selected node was deleted, the topology is recovered from other sources. The StorageClass may be nil. Only
+// transient failures are returned as error.
+func (r *Reconciler) getVolumeTopology(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, sc *storagev1.StorageClass) (volumeTopology, error) {
+	// if the annotation 'volume.kubernetes.io/selected-node' is not set, the volume was bound immediately and its
+	// topology can only be taken from the allowed topologies of the storage class.
+	assignedNodeName := pvc.Annotations[selectedNodeAnnotationKey]
+	if assignedNodeName == "" {
+		if sc == nil {
+			return volumeTopology{}, nil
+		}
+		requirements, err := getAllowedTopologyRequirements(sc)
+		if err != nil {
+			return volumeTopology{
+				reason:  reasonAmbiguousTopology,
+				message: fmt.Sprintf("no node selected and the topology of storage class %s is ambiguous: %v", sc.Name, err),
+			}, nil
+		}
+		return volumeTopology{requirements: requirements, source: "storage class " + sc.Name}, nil
+	}
+
+	assignedNode := &corev1.Node{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: assignedNodeName}, assignedNode); err != nil {
+		if apierrors.IsNotFound(err) {
+			// If the assigned node is not found, it has been deleted and retrying doesn't help.
+			return r.recoverVolumeTopology(ctx, pv, sc, assignedNodeName)
 		}
 
-		assignedNode := &corev1.Node{}
-		if err := r.Client.Get(ctx, client.ObjectKey{Name: assignedNodeName}, assignedNode); err != nil {
-			if client.IgnoreNotFound(err) == nil {
-				// If the assigned node is not found, it might have been deleted. If the NodeAffinity is already set, we can ignore this error, otherwise we should return it to trigger a retry.
-				if pv.Spec.NodeAffinity != nil {
-					return nil
-				}
-				return fmt.Errorf("assigned node %s not found for pvc %s and NodeAffinity is not set", assignedNodeName, pvc.Name)
+		return volumeTopology{}, reconcileError{reasonGetNodeFailed, fmt.Errorf("failed to get node %s: %w", assignedNodeName, err)}
+	}
+
+	return volumeTopology{
+		requirements: getNodeAffinityRequirements(assignedNode, r.getTopologyKeys(sc)),
+		source:       "node " + assignedNode.Name,
+	}, nil
+}
+
+// patchNodeAffinity adds the requirements that are missing in the node affinity of the PV. The source of the
+// requirements is only used for reporting. The PV is patched with an optimistic lock and re-read on conflicts, so that
+// concurrent changes are never overwritten. Existing requirements are kept, if they contradict the requirements or the
+// API server rejects changing the node affinity this is reported once by marking the PVC. The outcome is returned as
+// audit status, it is empty if the PV was deleted.
+func (r *Reconciler) patchNodeAffinity(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, requirements []corev1.NodeSelectorRequirement, source string) (AuditStatus, error) {
+	status := AuditMatching
+	retried := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if retried {
+			if err := r.Client.Get(ctx, client.ObjectKeyFromObject(pv), pv); err != nil {
+				return err
 			}
+		}
+		retried = true
+
+		affinity, changed, err := mergeNodeAffinity(pv.Spec.NodeAffinity, requirements)
+		if err != nil {
+			return errNodeAffinityConflict{err}
+		}
+		if !changed {
+			return nil
+		}
 
+		original := pv.DeepCopy()
+		patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+		pv.Spec.NodeAffinity = affinity
+		if err := r.Client.Patch(ctx, pv, patch); err != nil {
+			// The PV keeps its node affinity if the patch is rejected.
+			pv.Spec.NodeAffinity = original.Spec.NodeAffinity
 			return err
 		}
+		status = AuditPatched
+		pvNodeAffinityPatches.Inc()
+		r.Recorder.Eventf(pvc, pv, corev1.EventTypeNormal, reasonNodeAffinitySet, "SetNodeAffinity", "Set node affinity of volume %s from %s", pv.Name, source)
+		r.Recorder.Eventf(pv, pvc, corev1.EventTypeNormal, reasonNodeAffinitySet, "SetNodeAffinity", "Set node affinity from %s", source)
+		return nil
+	})
 
-		zone := assignedNode.Labels["topology.kubernetes.io/zone"]
-		region := assignedNode.Labels["topology.kubernetes.io/region"]
+	var conflict errNodeAffinityConflict
+	switch {
+	case errors.As(err, &conflict):
+		return AuditConflicting, r.reportNodeAffinityRejected(ctx, pvc, pv, reasonNodeAffinityConflict, fmt.Sprintf("node affinity of volume %s contradicts the topology of %s: %v", pv.Name, source, conflict.err))
+	case apierrors.IsNotFound(err):
+		r.recordWarning(pvc, nil, reasonVolumeNotFound, "Bound volume %s not found", pv.Name)
+		return "", nil
+	case apierrors.IsInvalid(err):
+		// Clusters without mutable PV node affinity reject changing an affinity that is already set.
+		return AuditRejected, r.reportNodeAffinityRejected(ctx, pvc, pv, reasonNodeAffinityRejected, fmt.Sprintf("node affinity of volume %s can't be set: %v", pv.Name, err))
+	case err != nil:
+		return "", reconcileError{reasonPatchVolumeFailed, fmt.Errorf("failed to patch pv %s: %w", pv.Name, err)}
+	}
+	_, err = r.setClaimAnnotation(ctx, pvc, nodeAffinityRejectedAnnotationKey, "")
+	return status, err
+}
 
-		var matchExpressions []corev1.NodeSelectorRequirement
+// hasNodeAffinity reports whether the node affinity of the PV restricts the nodes it can be used on.
+func hasNodeAffinity(pv *corev1.PersistentVolume) bool {
+	affinity := pv.Spec.NodeAffinity
+	return affinity != nil && affinity.Required != nil && len(affinity.Required.NodeSelectorTerms) > 0
+}
 
-		if zone != "" {
-			matchExpressions = append(matchExpressions, corev1.NodeSelectorRequirement{
-				Key:      "topology.kubernetes.io/zone",
-				Operator: corev1.NodeSelectorOpIn,
-				Values:   []string{zone},
-			})
-		}
+// reportNodeAffinityRejected marks the PVC with the reason the node affinity of its PV can't be set. The warning is
+// only recorded if the mark changed, so that it isn't repeated on every reconciliation.
+func (r *Reconciler) reportNodeAffinityRejected(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, reason, message string) error {
+	marked, err := r.setClaimAnnotation(ctx, pvc, nodeAffinityRejectedAnnotationKey, message)
+	if err != nil {
+		return err
+	}
+	if marked {
+		r.recordWarning(pvc, pv, reason, "Node affinity can't be set: %s", message)
+	}
+	return nil
+}
 
-		if region != "" {
-			matchExpressions = append(matchExpressions, corev1.NodeSelectorRequirement{
-				Key:      "topology.kubernetes.io/region",
-				Operator: corev1.NodeSelectorOpIn,
-				Values:   []string{region},
-			})
+// setClaimAnnotation sets the annotation of the PVC to the message, or removes it if the message is empty. It reports
+// whether the PVC changed.
+func (r *Reconciler) setClaimAnnotation(ctx context.Context, pvc *corev1.PersistentVolumeClaim, key, message string) (bool, error) {
+	if pvc.Annotations[key] == message {
+		return false, nil
+	}
+
+	patch := client.MergeFrom(pvc.DeepCopy())
+	if message == "" {
+		delete(pvc.Annotations, key)
+	} else {
+		if pvc.Annotations == nil {
+			pvc.Annotations = make(map[string]string, 1)
 		}
+		pvc.Annotations[key] = message
+	}
+	if err := r.Client.Patch(ctx, pvc, patch); err != nil {
+		return false, reconcileError{reasonPatchClaimFailed, fmt.Errorf("failed to patch pvc %s: %w", pvc.Name, err)}
+	}
+	return true, nil
+}
 
-		if len(matchExpressions) > 0 {
-			pv.Spec.NodeAffinity = &corev1.VolumeNodeAffinity{
-				Required: &corev1.NodeSelector{
-					NodeSelectorTerms: []corev1.NodeSelectorTerm{
-						{
-							MatchExpressions: matchExpressions,
-						},
-					},
-				},
-			}
+// errNodeAffinityConflict is returned if the node affinity of a PV contradicts the desired topology.
+type errNodeAffinityConflict struct {
+	err error
+}
 
-			pv = pv.DeepCopy()
-			if err := r.Client.Update(ctx, pv); err != nil {
-				return err
-			}
-		}
+func (e errNodeAffinityConflict) Error() string {
+	return e.err.Error()
+}
+
+// reconcileError is a failure that is retried, the reason partitions the reconcile error metric.
+type reconcileError struct {
+	reason string
+	err    error
+}
+
+func (e reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e reconcileError) Unwrap() error {
+	return e.err
+}
+
+// recordWarning records a warning event on the PVC and, if known, on its PV, each related to the other. It is used for
+// failures that aren't retried.
+// recordSkipped records a normal event on the PVC and its PV if the node affinity is left unchanged. The event is only
+// recorded if the reason differs from the last one recorded for the PVC, so that it isn't repeated on every
+// reconciliation.
+func (r *Reconciler) recordSkipped(pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, reason, note string, args ...interface{}) {
+	if last, loaded := r.skipped.Swap(client.ObjectKeyFromObject(pvc), reason); loaded && last == reason {
+		return
 	}
+	r.Recorder.Eventf(pvc, pv, corev1.EventTypeNormal, reason, "SetNodeAffinity", note, args...)
+	r.Recorder.Eventf(pv, pvc, corev1.EventTypeNormal, reason, "SetNodeAffinity", note, args...)
+}
 
-	return nil
+func (r *Reconciler) recordWarning(pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, reason, note string, args ...interface{}) {
+	if pv == nil {
+		r.Recorder.Eventf(pvc, nil, corev1.EventTypeWarning, reason, "SetNodeAffinity", note, args...)
+		return
+	}
+	r.Recorder.Eventf(pvc, pv, corev1.EventTypeWarning, reason, "SetNodeAffinity", note, args...)
+	r.Recorder.Eventf(pv, pvc, corev1.EventTypeWarning, reason, "SetNodeAffinity", note, args...)
 }
@@ -0,0 +1,293 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// regardingRecorder records the objects events are recorded on.
+type regardingRecorder struct {
+	events.FakeRecorder
+	regarding []runtime.Object
+}
+
+func (r *regardingRecorder) Eventf(regarding runtime.Object, related runtime.Object, eventtype, reason, action, note string, args ...interface{}) {
+	r.regarding = append(r.regarding, regarding)
+}
+
+var _ = Describe("Patch node affinity", func() {
+	var testReconcile *Reconciler
+	var testClient client.Client
+	var testRecorder *events.FakeRecorder
+	var pvc *corev1.PersistentVolumeClaim
+	var pv *corev1.PersistentVolume
+	requirements := []corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}
+
+	BeforeEach(func() {
+		pvc = createTestClaim(ptr.To("kubevirt"), "pv", corev1.ClaimBound)
+		pv = createTestVolume("pv", provisioner)
+	})
+
+	JustBeforeEach(func() {
+		testClient = newIndexedClientBuilder(pvc, pv).Build()
+		testRecorder = events.NewFakeRecorder(10)
+		testReconcile = &Reconciler{Client: testClient, Recorder: testRecorder}
+	})
+
+	It("should set the node affinity of a volume without affinity", func() {
+		Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditPatched))
+		updated := &corev1.PersistentVolume{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(pv), updated)).NotTo(HaveOccurred())
+		Expect(updated.Spec.NodeAffinity).Should(Equal(createTestAffinity(requirements)))
+	})
+
+	It("should only report a deleted volume without counting it as reconcile error", func() {
+		Expect(testClient.Delete(context.TODO(), pv)).NotTo(HaveOccurred())
+		errors := testutil.ToFloat64(reconcileErrors.WithLabelValues(reasonVolumeNotFound))
+		Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(reconcileErrors.WithLabelValues(reasonVolumeNotFound))).Should(Equal(errors))
+		Expect(testRecorder.Events).Should(Receive(ContainSubstring(reasonVolumeNotFound)))
+	})
+
+	Context("When the node affinity is already set", func() {
+		region := corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyRegion, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu"}}
+
+		BeforeEach(func() {
+			pv.Spec.NodeAffinity = createTestAffinity([]corev1.NodeSelectorRequirement{region})
+		})
+
+		It("should add the missing requirements to the node affinity", func() {
+			Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditPatched))
+			updated := &corev1.PersistentVolume{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(pv), updated)).NotTo(HaveOccurred())
+			Expect(updated.Spec.NodeAffinity).Should(Equal(createTestAffinity(append([]corev1.NodeSelectorRequirement{region}, requirements...))))
+			Expect(pvc.Annotations).ShouldNot(HaveKey(nodeAffinityRejectedAnnotationKey))
+		})
+
+		Context("When the API server rejects changing it", func() {
+			JustBeforeEach(func() {
+				testClient = interceptor.NewClient(testClient.(client.WithWatch), interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						if _, ok := obj.(*corev1.PersistentVolume); ok {
+							return apierrors.NewInvalid(schema.GroupKind{Kind: "PersistentVolume"}, obj.GetName(), field.ErrorList{field.Forbidden(field.NewPath("spec", "nodeAffinity"), "field is immutable")})
+						}
+						return c.Patch(ctx, obj, patch, opts...)
+					},
+				})
+				testReconcile.Client = testClient
+			})
+
+			It("should report the rejection once", func() {
+				for range 2 {
+					Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditRejected))
+				}
+				updated := &corev1.PersistentVolume{}
+				Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(pv), updated)).NotTo(HaveOccurred())
+				Expect(updated.Spec.NodeAffinity).Should(Equal(createTestAffinity([]corev1.NodeSelectorRequirement{region})))
+				Expect(pvc.Annotations).Should(HaveKey(nodeAffinityRejectedAnnotationKey))
+				Expect(testRecorder.Events).Should(Receive(ContainSubstring(reasonNodeAffinityRejected)))
+			})
+
+			It("should clear the mark once the node affinity matches", func() {
+				Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditRejected))
+				Expect(pvc.Annotations).Should(HaveKey(nodeAffinityRejectedAnnotationKey))
+				Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, []corev1.NodeSelectorRequirement{region}, "node")).Should(Equal(AuditMatching))
+				Expect(pvc.Annotations).ShouldNot(HaveKey(nodeAffinityRejectedAnnotationKey))
+			})
+		})
+	})
+
+	Context("When one of several node selector terms allows the requirements", func() {
+		BeforeEach(func() {
+			pv.Spec.NodeAffinity = createTestAffinity(
+				[]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")},
+				[]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")},
+			)
+		})
+
+		It("should not report a conflict", func() {
+			Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditMatching))
+			Expect(pvc.Annotations).ShouldNot(HaveKey(nodeAffinityRejectedAnnotationKey))
+			Expect(testRecorder.Events).Should(BeEmpty())
+		})
+	})
+
+	Context("When the node affinity contradicts the requirements", func() {
+		BeforeEach(func() {
+			pv.Spec.NodeAffinity = createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")})
+		})
+
+		It("should report the conflict once", func() {
+			for range 2 {
+				Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditConflicting))
+			}
+			Expect(pvc.Annotations).Should(HaveKey(nodeAffinityRejectedAnnotationKey))
+			Expect(testRecorder.Events).Should(HaveLen(2))
+			Expect(testRecorder.Events).Should(Receive(ContainSubstring(reasonNodeAffinityConflict)))
+		})
+
+		It("should record the conflict on the PVC and the PV", func() {
+			recorder := &regardingRecorder{}
+			testReconcile.Recorder = recorder
+			Expect(testReconcile.patchNodeAffinity(context.TODO(), pvc, pv, requirements, "node")).Should(Equal(AuditConflicting))
+			Expect(recorder.regarding).Should(ConsistOf(
+				BeAssignableToTypeOf(&corev1.PersistentVolumeClaim{}),
+				BeAssignableToTypeOf(&corev1.PersistentVolume{}),
+			))
+		})
+	})
+})
+
+var _ = Describe("Reconcile PVCs without selected node", func() {
+	var testClient client.Client
+	var testRecorder *events.FakeRecorder
+	var testReconcile *Reconciler
+
+	createTestStorageClass := func(name, key string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: name},
+			Provisioner: provisioner,
+			AllowedTopologies: []corev1.TopologySelectorTerm{
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: key, Values: []string{"a", "b"}}}},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		testClient = newIndexedClientBuilder(
+			createTestStorageClass("multi-zone", corev1.LabelTopologyZone),
+			createTestVolume("pv", provisioner),
+		).Build()
+		testRecorder = events.NewFakeRecorder(10)
+		testReconcile = &Reconciler{Client: testClient, Recorder: testRecorder}
+	})
+
+	It("should report an ambiguous topology once as warning", func() {
+		pvc := createTestClaim(ptr.To("multi-zone"), "pv", corev1.ClaimBound)
+		Expect(testClient.Create(context.TODO(), pvc)).NotTo(HaveOccurred())
+		for range 2 {
+			Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+		}
+		Expect(pvc.Annotations).Should(HaveKey(topologyUnknownAnnotationKey))
+		Expect(testRecorder.Events).Should(HaveLen(2))
+		Expect(testRecorder.Events).Should(Receive(HavePrefix(corev1.EventTypeWarning + " " + reasonAmbiguousTopology)))
+	})
+})
+
+var _ = Describe("Reconcile PVCs with selected node", func() {
+	It("should keep pinning the configured keys of classes restricting only zones", func() {
+		node := createTestNode("node-1", "a")
+		node.Labels[corev1.LabelTopologyRegion] = "eu"
+		pvc := createTestClaim(ptr.To("zonal"), "pv", corev1.ClaimBound)
+		pvc.Annotations = map[string]string{selectedNodeAnnotationKey: node.Name}
+		testClient := newIndexedClientBuilder(
+			&storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "zonal"},
+				Provisioner: provisioner,
+				AllowedTopologies: []corev1.TopologySelectorTerm{
+					{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a", "b"}}}},
+				},
+			},
+			node, pvc, createTestVolume("pv", provisioner),
+		).Build()
+		testReconcile := &Reconciler{Client: testClient, Recorder: events.NewFakeRecorder(10)}
+
+		Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+		updated := &corev1.PersistentVolume{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "pv"}, updated)).NotTo(HaveOccurred())
+		Expect(updated.Spec.NodeAffinity).Should(Equal(createTestAffinity([]corev1.NodeSelectorRequirement{
+			zoneRequirement(corev1.NodeSelectorOpIn, "a"),
+			{Key: corev1.LabelTopologyRegion, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu"}},
+		})))
+	})
+
+	Context("When reconciliation is skipped", func() {
+		var testClient client.Client
+		var testRecorder *events.FakeRecorder
+		var testReconcile *Reconciler
+		var node *corev1.Node
+		var pvc *corev1.PersistentVolumeClaim
+		var pv *corev1.PersistentVolume
+
+		BeforeEach(func() {
+			node = createTestNode("node-1", "a")
+			pvc = createTestClaim(ptr.To("kubevirt"), "pv", corev1.ClaimBound)
+			pvc.Annotations = map[string]string{selectedNodeAnnotationKey: node.Name}
+			pv = createTestVolume("pv", provisioner)
+		})
+
+		JustBeforeEach(func() {
+			testClient = newIndexedClientBuilder(
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "kubevirt"}, Provisioner: provisioner},
+				node, pvc, pv,
+			).Build()
+			testRecorder = events.NewFakeRecorder(10)
+			testReconcile = &Reconciler{Client: testClient, Recorder: testRecorder}
+		})
+
+		Context("When the node affinity already matches", func() {
+			BeforeEach(func() {
+				pv.Spec.NodeAffinity = createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")})
+			})
+
+			It("should report it once", func() {
+				for range 2 {
+					Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+				}
+				Expect(testRecorder.Events).Should(HaveLen(2))
+				Expect(testRecorder.Events).Should(Receive(HavePrefix(corev1.EventTypeNormal + " " + reasonNodeAffinityUpToDate)))
+			})
+		})
+
+		Context("When the node has no topology labels", func() {
+			BeforeEach(func() {
+				node.Labels = nil
+			})
+
+			It("should report it once", func() {
+				for range 2 {
+					Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+				}
+				Expect(testRecorder.Events).Should(HaveLen(2))
+				Expect(testRecorder.Events).Should(Receive(HavePrefix(corev1.EventTypeNormal + " " + reasonNoTopology)))
+			})
+		})
+
+		It("should report the skip again once the affinity was set in between", func() {
+			Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+			Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+			Expect(testRecorder.Events).Should(HaveLen(4))
+			Expect(testRecorder.Events).Should(Receive(HavePrefix(corev1.EventTypeNormal + " " + reasonNodeAffinitySet)))
+		})
+	})
+})
@@ -0,0 +1,100 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// topologyUnknownAnnotationKey marks PVCs whose volume topology can't be determined, its value explains why.
+const topologyUnknownAnnotationKey = "csiprovisioner.kubevirt.io/topology-unknown"
+
+// recoverVolumeTopology infers the topology of a volume whose selected node was deleted. The topology is taken from
+// the nodes the volume is attached to, the allowed topologies of the StorageClass or the topology keys in the volume
+// attributes of the PV, in that order. The StorageClass may be nil.
+func (r *Reconciler) recoverVolumeTopology(ctx context.Context, pv *corev1.PersistentVolume, sc *storagev1.StorageClass, assignedNodeName string) (volumeTopology, error) {
+	topologyKeys := r.getTopologyKeys(sc)
+
+	topology, err := r.getVolumeAttachmentTopology(ctx, pv, topologyKeys)
+	if err != nil || len(topology.requirements) > 0 {
+		return topology, err
+	}
+
+	if sc != nil {
+		if requirements, err := getAllowedTopologyRequirements(sc); err == nil && len(requirements) > 0 {
+			return volumeTopology{requirements: requirements, source: "storage class " + sc.Name}, nil
+		}
+	}
+
+	if pv.Spec.CSI != nil {
+		var requirements []corev1.NodeSelectorRequirement
+		for _, key := range topologyKeys {
+			if value := pv.Spec.CSI.VolumeAttributes[key]; value != "" {
+				requirements = append(requirements, corev1.NodeSelectorRequirement{
+					Key:      key,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{value},
+				})
+			}
+		}
+		if len(requirements) > 0 {
+			return volumeTopology{requirements: requirements, source: "volume attributes of " + pv.Name}, nil
+		}
+	}
+
+	return volumeTopology{
+		reason:  reasonNodeNotFound,
+		message: fmt.Sprintf("selected node %s not found and the topology can't be recovered from volume attachments, storage class or volume attributes", assignedNodeName),
+	}, nil
+}
+
+// getVolumeAttachmentTopology returns the topology of the existing nodes the volume is attached to. No requirements
+// are returned if these nodes disagree on the topology.
+func (r *Reconciler) getVolumeAttachmentTopology(ctx context.Context, pv *corev1.PersistentVolume, topologyKeys []string) (volumeTopology, error) {
+	volumeAttachments := &storagev1.VolumeAttachmentList{}
+	if err := r.Client.List(ctx, volumeAttachments, client.MatchingFields{volumeAttachmentVolumeNameIndex: pv.Name}); err != nil {
+		return volumeTopology{}, reconcileError{reasonGetVolumeAttachmentsFailed, fmt.Errorf("failed to list volume attachments of pv %s: %w", pv.Name, err)}
+	}
+
+	var topology volumeTopology
+	for _, volumeAttachment := range volumeAttachments.Items {
+		node := &corev1.Node{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: volumeAttachment.Spec.NodeName}, node); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return volumeTopology{}, reconcileError{reasonGetNodeFailed, fmt.Errorf("failed to get node %s: %w", volumeAttachment.Spec.NodeName, err)}
+		}
+
+		requirements := getNodeAffinityRequirements(node, topologyKeys)
+		if len(requirements) == 0 {
+			continue
+		}
+		if topology.requirements != nil && !equality.Semantic.DeepEqual(topology.requirements, requirements) {
+			return volumeTopology{}, nil
+		}
+		topology = volumeTopology{requirements: requirements, source: "node " + node.Name + " the volume is attached to"}
+	}
+	return topology, nil
+}
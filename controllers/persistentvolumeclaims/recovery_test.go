@@ -0,0 +1,126 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func createTestNode(name, zone string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelTopologyZone: zone}}}
+}
+
+func createTestVolumeAttachment(name, pvName, nodeName string) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: provisioner,
+			NodeName: nodeName,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To(pvName)},
+		},
+	}
+}
+
+var _ = Describe("Topology recovery", func() {
+	var testReconcile *Reconciler
+	var testClient client.Client
+	var testRecorder *events.FakeRecorder
+	var objects []client.Object
+	var pv *corev1.PersistentVolume
+
+	BeforeEach(func() {
+		pv = createTestVolume("pv", provisioner)
+		objects = []client.Object{createTestNode("node-a", "a"), createTestNode("node-b", "b")}
+	})
+
+	JustBeforeEach(func() {
+		testClient = newIndexedClientBuilder(append(objects, pv)...).Build()
+		testRecorder = events.NewFakeRecorder(10)
+		testReconcile = &Reconciler{Client: testClient, Recorder: testRecorder}
+	})
+
+	Context("When the volume is attached to an existing node", func() {
+		BeforeEach(func() {
+			objects = append(objects, createTestVolumeAttachment("va-deleted", "pv", "deleted"), createTestVolumeAttachment("va-a", "pv", "node-a"))
+		})
+
+		It("should take the topology from the node", func() {
+			topology, err := testReconcile.recoverVolumeTopology(context.TODO(), pv, nil, "deleted")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topology.requirements).Should(Equal([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")}))
+		})
+	})
+
+	Context("When the attached nodes disagree on the topology", func() {
+		BeforeEach(func() {
+			objects = append(objects, createTestVolumeAttachment("va-a", "pv", "node-a"), createTestVolumeAttachment("va-b", "pv", "node-b"))
+		})
+
+		It("should take the topology from the storage class", func() {
+			sc := &storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "kubevirt"},
+				Provisioner: provisioner,
+				AllowedTopologies: []corev1.TopologySelectorTerm{
+					{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"b"}}}},
+				},
+			}
+			topology, err := testReconcile.recoverVolumeTopology(context.TODO(), pv, sc, "deleted")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topology.requirements).Should(Equal([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "b")}))
+		})
+	})
+
+	Context("When the volume attributes contain the topology", func() {
+		BeforeEach(func() {
+			pv.Spec.CSI.VolumeAttributes = map[string]string{corev1.LabelTopologyZone: "c"}
+		})
+
+		It("should take the topology from the volume attributes", func() {
+			topology, err := testReconcile.recoverVolumeTopology(context.TODO(), pv, nil, "deleted")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topology.requirements).Should(Equal([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "c")}))
+		})
+	})
+
+	It("should mark the PVC once if the topology can't be recovered", func() {
+		pvc := createTestClaim(nil, "pv", corev1.ClaimBound)
+		pvc.Annotations = map[string]string{selectedNodeAnnotationKey: "deleted"}
+		Expect(testClient.Create(context.TODO(), pvc)).NotTo(HaveOccurred())
+		for range 2 {
+			Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+		}
+		Expect(pvc.Annotations).Should(HaveKey(topologyUnknownAnnotationKey))
+		Expect(testRecorder.Events).Should(HaveLen(2))
+		Expect(testRecorder.Events).Should(Receive(ContainSubstring(reasonNodeNotFound)))
+
+		Expect(testClient.Create(context.TODO(), createTestVolumeAttachment("va-a", "pv", "node-a"))).NotTo(HaveOccurred())
+		Expect(testReconcile.reconcilePVCs(context.TODO(), pvc)).NotTo(HaveOccurred())
+		Expect(pvc.Annotations).ShouldNot(HaveKey(topologyUnknownAnnotationKey))
+		updated := &corev1.PersistentVolume{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKeyFromObject(pv), updated)).NotTo(HaveOccurred())
+		Expect(updated.Spec.NodeAffinity).Should(Equal(createTestAffinity([]corev1.NodeSelectorRequirement{zoneRequirement(corev1.NodeSelectorOpIn, "a")})))
+	})
+})
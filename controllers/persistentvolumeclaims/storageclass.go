@@ -0,0 +1,102 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// getKubevirtStorageClasses returns the StorageClasses provisioned by csi.kubevirt.io.
+func (r *Reconciler) getKubevirtStorageClasses(ctx context.Context) ([]storagev1.StorageClass, error) {
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, storageClasses, client.MatchingFields{storageClassProvisionerIndex: provisioner}); err != nil {
+		return nil, err
+	}
+	return storageClasses.Items, nil
+}
+
+// getKubevirtStorageClass reports whether the volume of the PVC is provisioned by csi.kubevirt.io and returns its
+// StorageClass. PVCs created without a storageClassName got the default class assigned to their PV, so the effective
+// class is taken from the bound PV in that case. If the PV has no class either or the class was deleted, the CSI driver
+// of the PV decides and no StorageClass is returned. The PV may be nil if it is not known yet.
+func (r *Reconciler) getKubevirtStorageClass(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) (*storagev1.StorageClass, bool, error) {
+	storageClassName := ""
+	if pvc.Spec.StorageClassName != nil {
+		storageClassName = *pvc.Spec.StorageClassName
+	}
+	if storageClassName == "" && pv != nil {
+		storageClassName = pv.Spec.StorageClassName
+	}
+
+	if storageClassName != "" {
+		storageClasses, err := r.getKubevirtStorageClasses(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		i := slices.IndexFunc(storageClasses, func(sc storagev1.StorageClass) bool {
+			return sc.Name == storageClassName
+		})
+		if i >= 0 {
+			return &storageClasses[i], true, nil
+		}
+
+		// Classes of other provisioners are never handled, only deleted classes fall back to the driver of the PV.
+		err = r.Client.Get(ctx, client.ObjectKey{Name: storageClassName}, &storagev1.StorageClass{})
+		if !apierrors.IsNotFound(err) {
+			return nil, false, err
+		}
+	}
+
+	return nil, pv != nil && pv.Spec.CSI != nil && pv.Spec.CSI.Driver == provisioner, nil
+}
+
+// isKubevirtClaim reports whether the PVC is bound and its volume is provisioned by csi.kubevirt.io, which are the only
+// claims the controller acts on. Claims without a selected node are only accepted if their StorageClass restricts
+// the allowed topologies, as the topology of their volume can't be determined otherwise.
+func (r *Reconciler) isKubevirtClaim(ctx context.Context, pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return false
+	}
+	l := log.FromContext(ctx).WithValues("pvc-name", client.ObjectKeyFromObject(pvc))
+
+	// The PV is needed to resolve claims without storage class or whose class was deleted.
+	var pv *corev1.PersistentVolume
+	if pvc.Spec.VolumeName != "" {
+		pv = &corev1.PersistentVolume{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, pv); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				l.Error(err, "failed to get PV", "pv-name", pvc.Spec.VolumeName)
+				return false
+			}
+			pv = nil
+		}
+	}
+
+	sc, ok, err := r.getKubevirtStorageClass(ctx, pvc, pv)
+	if err != nil {
+		l.Error(err, "failed to get storage class")
+		return false
+	}
+	return ok && (pvc.Annotations[selectedNodeAnnotationKey] != "" || sc != nil && len(sc.AllowedTopologies) > 0)
+}
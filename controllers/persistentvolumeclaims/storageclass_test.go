@@ -0,0 +1,115 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistentvolumeclaims
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func createTestClaim(storageClassName *string, volumeName string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: storageClassName,
+			VolumeName:       volumeName,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func createTestVolume(name, driver string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name},
+			},
+		},
+	}
+}
+
+var _ = Describe("Kubevirt claim predicates", func() {
+	var testReconcile *Reconciler
+
+	// createSelectedClaim returns a claim whose volume was provisioned for node-1.
+	createSelectedClaim := func(storageClassName *string, volumeName string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+		pvc := createTestClaim(storageClassName, volumeName, phase)
+		pvc.Annotations = map[string]string{selectedNodeAnnotationKey: "node-1"}
+		return pvc
+	}
+
+	BeforeEach(func() {
+		testReconcile = &Reconciler{
+			Client: newIndexedClientBuilder(
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "kubevirt"}, Provisioner: provisioner},
+				&storagev1.StorageClass{
+					ObjectMeta:  metav1.ObjectMeta{Name: "kubevirt-zone-a"},
+					Provisioner: provisioner,
+					AllowedTopologies: []corev1.TopologySelectorTerm{
+						{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: corev1.LabelTopologyZone, Values: []string{"a"}}}},
+					},
+				},
+				&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "foreign"}, Provisioner: "foreign.csi.io"},
+				createTestVolume("pv-kubevirt", provisioner),
+				createTestVolume("pv-foreign", "foreign.csi.io"),
+			).Build(),
+		}
+	})
+
+	It("should only accept bound claims of kubevirt storage classes", func() {
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("kubevirt"), "pv-kubevirt", corev1.ClaimBound))).Should(BeTrue())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("kubevirt"), "", corev1.ClaimPending))).Should(BeFalse())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("foreign"), "pv-foreign", corev1.ClaimBound))).Should(BeFalse())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("foreign"), "pv-kubevirt", corev1.ClaimBound))).Should(BeFalse())
+	})
+
+	It("should only accept claims without selected node if their class restricts the topology", func() {
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createTestClaim(ptr.To("kubevirt"), "pv-kubevirt", corev1.ClaimBound))).Should(BeFalse())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createTestClaim(ptr.To("kubevirt-zone-a"), "pv-kubevirt", corev1.ClaimBound))).Should(BeTrue())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createTestClaim(nil, "pv-kubevirt", corev1.ClaimBound))).Should(BeFalse())
+	})
+
+	It("should resolve claims whose storage class was deleted by the driver of their volume", func() {
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("missing"), "pv-kubevirt", corev1.ClaimBound))).Should(BeTrue())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("missing"), "pv-foreign", corev1.ClaimBound))).Should(BeFalse())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To("missing"), "pv-missing", corev1.ClaimBound))).Should(BeFalse())
+
+		sc, ok, err := testReconcile.getKubevirtStorageClass(context.TODO(), createTestClaim(ptr.To("missing"), "pv-kubevirt", corev1.ClaimBound), createTestVolume("pv-kubevirt", provisioner))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).Should(BeTrue())
+		Expect(sc).Should(BeNil())
+	})
+
+	It("should resolve claims without storage class by the driver of their volume", func() {
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(nil, "pv-kubevirt", corev1.ClaimBound))).Should(BeTrue())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(ptr.To(""), "pv-foreign", corev1.ClaimBound))).Should(BeFalse())
+		Expect(testReconcile.isKubevirtClaim(context.TODO(), createSelectedClaim(nil, "pv-missing", corev1.ClaimBound))).Should(BeFalse())
+	})
+
+	It("should only accept volumes of the kubevirt CSI driver", func() {
+		Expect(isKubevirtVolume(createTestVolume("pv-kubevirt", provisioner))).Should(BeTrue())
+		Expect(isKubevirtVolume(createTestVolume("pv-foreign", "foreign.csi.io"))).Should(BeFalse())
+		Expect(isKubevirtVolume(&corev1.PersistentVolume{})).Should(BeFalse())
+	})
+})
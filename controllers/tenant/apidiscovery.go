@@ -0,0 +1,109 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"fmt"
+	"time"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// apiRecheckInterval is the interval in which missing optional APIs are checked again.
+	apiRecheckInterval = time.Minute
+
+	reasonAPIAvailable    = "APIAvailable"
+	reasonAPINotInstalled = "APINotInstalled"
+)
+
+// hasAPIResource reports whether the API server serves the resource in the group version.
+func (r *TenantReconciler) hasAPIResource(groupVersion schema.GroupVersion, resource string) (bool, error) {
+	resources, err := r.Discovery.ServerResourcesForGroupVersion(groupVersion.String())
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to discover resources of %s: %w", groupVersion, err)
+	}
+	for _, apiResource := range resources.APIResources {
+		if apiResource.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getAPICondition returns the condition reporting the availability of an optional API.
+func getAPICondition(tenant *csiprovisionerv1alpha1.Tenant, conditionType string, groupVersion schema.GroupVersion, resource string, available bool) metav1.Condition {
+	if available {
+		return metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: tenant.Generation,
+			Reason:             reasonAPIAvailable,
+			Message:            fmt.Sprintf("The %s API is available", groupVersion.WithResource(resource).GroupResource()),
+		}
+	}
+	return metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: tenant.Generation,
+		Reason:             reasonAPINotInstalled,
+		Message:            fmt.Sprintf("The %s API is not installed, reconciliation is skipped until its CRDs are installed", groupVersion.WithResource(resource).GroupResource()),
+	}
+}
+
+// setAPICondition sets the condition reporting the availability of an optional API and reports whether the
+// reconciliation has to be retried until the API is installed. A missing API is only reported and waited for if the
+// tenant requests objects of it, otherwise the condition is removed. Objects requested later trigger a reconciliation
+// of the tenant anyway.
+func (r *TenantReconciler) setAPICondition(tenant *csiprovisionerv1alpha1.Tenant, conditionType string, groupVersion schema.GroupVersion, resource string, available, requested bool) bool {
+	if !available && !requested {
+		meta.RemoveStatusCondition(&tenant.Status.Conditions, conditionType)
+		return false
+	}
+	r.setCondition(tenant, getAPICondition(tenant, conditionType, groupVersion, resource, available))
+	return !available
+}
+
+// ensureWatch starts watching objects of an optional API once it is available. Watches can't be set up
+// in SetupWithManager, since starting the informer of a missing API fails.
+func (r *TenantReconciler) ensureWatch(name string, obj client.Object) error {
+	r.watchesLock.Lock()
+	defer r.watchesLock.Unlock()
+
+	if r.watches[name] {
+		return nil
+	}
+	if err := r.controller.Watch(source.Kind[client.Object](r.cache, obj, handler.EnqueueRequestsFromMapFunc(enqueueTenant))); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", name, err)
+	}
+	if r.watches == nil {
+		r.watches = make(map[string]bool)
+	}
+	r.watches[name] = true
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/events"
+)
+
+var _ = Describe("API discovery", func() {
+	var testReconcile *TenantReconciler
+	var testDiscovery *fakediscovery.FakeDiscovery
+	BeforeEach(func() {
+		testDiscovery = &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		testReconcile = &TenantReconciler{Discovery: testDiscovery}
+	})
+
+	It("should report a missing API group as not available", func() {
+		available, err := testReconcile.hasAPIResource(snapshotv1.SchemeGroupVersion, "volumesnapshotclasses")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(available).Should(BeFalse())
+	})
+
+	It("should report a served resource as available", func() {
+		testDiscovery.Resources = []*metav1.APIResourceList{{
+			GroupVersion: snapshotv1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "volumesnapshotclasses"}},
+		}}
+		available, err := testReconcile.hasAPIResource(snapshotv1.SchemeGroupVersion, "volumesnapshotclasses")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(available).Should(BeTrue())
+	})
+	It("should only report and wait for a missing API if objects of it are requested", func() {
+		testReconcile.Recorder = events.NewFakeRecorder(10)
+		testTenant := createTestTenant(nil)
+		requeue := testReconcile.setAPICondition(testTenant, v1alpha1.ConditionVolumeSnapshotAPIAvailable, snapshotv1.SchemeGroupVersion, "volumesnapshotclasses", false, false)
+		Expect(requeue).Should(BeFalse())
+		Expect(testTenant.Status.Conditions).Should(BeEmpty())
+
+		requeue = testReconcile.setAPICondition(testTenant, v1alpha1.ConditionVolumeSnapshotAPIAvailable, snapshotv1.SchemeGroupVersion, "volumesnapshotclasses", false, true)
+		Expect(requeue).Should(BeTrue())
+		Expect(meta.IsStatusConditionFalse(testTenant.Status.Conditions, v1alpha1.ConditionVolumeSnapshotAPIAvailable)).Should(BeTrue())
+	})
+})
@@ -0,0 +1,220 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// fieldManager is the field manager of all objects applied by the operator.
+	fieldManager = "kubevirt-csi-driver-operator"
+	// legacyFieldManager is the field manager the API server derived from the user agent of the operator binary,
+	// when objects were still written with Create and Update.
+	legacyFieldManager = "manager"
+	// desiredStateHashAnnotationKey holds the hash of the last applied desired state of an object.
+	desiredStateHashAnnotationKey = "csiprovisioner.kubevirt.io/desired-state-hash"
+
+	reasonFieldConflict = "FieldConflict"
+)
+
+// getDesiredState returns the unstructured content of the desired object annotated with the hash of its content.
+// Only fields set on the desired object are part of it, so that the operator doesn't claim fields it doesn't render.
+func getDesiredState(desired client.Object, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(desired, scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: pruneNilValues(content)}
+	unstructured.RemoveNestedField(u.Object, "status")
+	u.SetGroupVersionKind(gvk)
+
+	// Map keys are sorted when marshalling, so the hash is stable.
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[desiredStateHashAnnotationKey] = hex.EncodeToString(sum[:])
+	u.SetAnnotations(annotations)
+	return u, nil
+}
+
+// renderedFieldsMatch reports whether all fields set in the desired content have the same value in the current
+// content. Fields only set on the current object, like defaults or fields of other managers, are ignored.
+func renderedFieldsMatch(desired, current interface{}) bool {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		// Empty maps and lists aren't stored, so a missing current value matches them.
+		currentMap, ok := current.(map[string]interface{})
+		if !ok && current != nil {
+			return false
+		}
+		for key, value := range desired {
+			if !renderedFieldsMatch(value, currentMap[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		currentList, ok := current.([]interface{})
+		if (!ok && current != nil) || len(currentList) != len(desired) {
+			return false
+		}
+		for i := range desired {
+			if !renderedFieldsMatch(desired[i], currentList[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equality.Semantic.DeepEqual(desired, current)
+	}
+}
+
+// pruneNilValues removes null values, like unset timestamps, from the unstructured content.
+func pruneNilValues(content map[string]interface{}) map[string]interface{} {
+	for key, value := range content {
+		switch value := value.(type) {
+		case nil:
+			delete(content, key)
+		case map[string]interface{}:
+			pruneNilValues(value)
+		case []interface{}:
+			for _, item := range value {
+				if item, ok := item.(map[string]interface{}); ok {
+					pruneNilValues(item)
+				}
+			}
+		}
+	}
+	return content
+}
+
+// apply server-side applies the desired object with the operator field manager. The write is skipped if the
+// object is annotated with the hash of the same desired state and all rendered fields still have their desired
+// values, so changes of rendered fields by others are reverted. If fields are owned by another field manager, the
+// conflict is reported with an event and the ownership of the fields is forced, as the operator is the source of
+// truth for the fields it renders.
+func (r *TenantReconciler) apply(ctx context.Context, desired client.Object) error {
+	desiredState, err := getDesiredState(desired, r.Client.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to build apply configuration for %s: %w", desired.GetName(), err)
+	}
+	applyConfiguration := client.ApplyConfigurationFromUnstructured(desiredState)
+	gvk := desiredState.GroupVersionKind()
+	kind := gvk.Kind
+
+	newObj, err := r.Client.Scheme().New(gvk)
+	if err != nil {
+		return fmt.Errorf("failed to create object of %s: %w", gvk, err)
+	}
+	current := newObj.(client.Object)
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(desired), current)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	found := err == nil
+	if found {
+		if err := r.upgradeManagedFields(ctx, current); err != nil {
+			return err
+		}
+		upToDate, err := isUpToDate(desiredState, current)
+		if err != nil {
+			return err
+		}
+		if upToDate {
+			objectWrites.WithLabelValues(kind, writeResultSkipped).Inc()
+			return nil
+		}
+	}
+
+	err = r.Client.Apply(ctx, applyConfiguration, client.FieldOwner(fieldManager))
+	if err == nil {
+		objectWrites.WithLabelValues(kind, writeResultApplied).Inc()
+	}
+	if !apierrors.IsConflict(err) {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Forcing ownership of conflicting fields", "name", desired.GetName(), "conflict", err.Error())
+	if found {
+		r.Recorder.Eventf(current, nil, corev1.EventTypeWarning, reasonFieldConflict, "Apply", "Forcing ownership of fields managed by others: %v", err)
+	}
+	if err := r.Client.Apply(ctx, applyConfiguration, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return err
+	}
+	objectWrites.WithLabelValues(kind, writeResultApplied).Inc()
+	return nil
+}
+
+// isUpToDate reports whether the current object was applied with the desired state and none of the rendered fields
+// was changed since.
+func isUpToDate(desiredState *unstructured.Unstructured, current client.Object) (bool, error) {
+	if current.GetDeletionTimestamp() != nil || current.GetAnnotations()[desiredStateHashAnnotationKey] != desiredState.GetAnnotations()[desiredStateHashAnnotationKey] {
+		return false, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert %s: %w", current.GetName(), err)
+	}
+	currentState := &unstructured.Unstructured{Object: content}
+	currentState.SetGroupVersionKind(desiredState.GroupVersionKind())
+	return renderedFieldsMatch(desiredState.Object, currentState.Object), nil
+}
+
+// upgradeManagedFields moves the fields owned by the legacy Update field manager to the operator field manager, so
+// that fields the operator no longer renders are removed by the next apply instead of being kept by the old manager.
+func (r *TenantReconciler) upgradeManagedFields(ctx context.Context, current client.Object) error {
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(current, sets.New(legacyFieldManager), fieldManager)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade managed fields of %s: %w", current.GetName(), err)
+	}
+	if patch == nil {
+		return nil
+	}
+	log.FromContext(ctx).Info("Upgrading managed fields to server-side apply", "name", current.GetName())
+	if err := r.Client.Patch(ctx, current, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+		return fmt.Errorf("failed to upgrade managed fields of %s: %w", current.GetName(), err)
+	}
+	return nil
+}
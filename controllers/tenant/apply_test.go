@@ -0,0 +1,104 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Apply", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	BeforeEach(func() {
+		testClient = fake.NewClientBuilder().WithReturnManagedFields().Build()
+		testReconcile = &TenantReconciler{
+			Client:   testClient,
+			Recorder: events.NewFakeRecorder(10),
+		}
+	})
+
+	It("should move fields of the legacy field manager to the operator field manager", func() {
+		legacy := &v1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+			DriverName: provisioner,
+			Parameters: map[string]string{"iops": "5000"},
+		}
+		Expect(testClient.Create(context.TODO(), legacy, client.FieldOwner(legacyFieldManager))).NotTo(HaveOccurred())
+
+		desired := &v1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+			DriverName: provisioner,
+		}
+		Expect(testReconcile.apply(context.TODO(), desired)).NotTo(HaveOccurred())
+		vac := v1.VolumeAttributesClass{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "gold"}, &vac)).NotTo(HaveOccurred())
+		for _, managedFields := range vac.ManagedFields {
+			Expect(managedFields.Manager).Should(Equal(fieldManager))
+		}
+		Expect(vac.Parameters).Should(BeEmpty())
+	})
+	It("should count applied and skipped writes", func() {
+		desired := &v1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "silver"},
+			DriverName: provisioner,
+		}
+		applied := testutil.ToFloat64(objectWrites.WithLabelValues("VolumeAttributesClass", writeResultApplied))
+		skipped := testutil.ToFloat64(objectWrites.WithLabelValues("VolumeAttributesClass", writeResultSkipped))
+
+		Expect(testReconcile.apply(context.TODO(), desired.DeepCopy())).NotTo(HaveOccurred())
+		Expect(testReconcile.apply(context.TODO(), desired.DeepCopy())).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(objectWrites.WithLabelValues("VolumeAttributesClass", writeResultApplied))).Should(Equal(applied + 1))
+		Expect(testutil.ToFloat64(objectWrites.WithLabelValues("VolumeAttributesClass", writeResultSkipped))).Should(Equal(skipped + 1))
+	})
+	It("should only skip the write if the rendered fields still have their desired values", func() {
+		desired := &v1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+			DriverName: provisioner,
+			Parameters: map[string]string{"iops": "5000"},
+		}
+		Expect(testReconcile.apply(context.TODO(), desired.DeepCopy())).NotTo(HaveOccurred())
+		vac := v1.VolumeAttributesClass{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "gold"}, &vac)).NotTo(HaveOccurred())
+		resourceVersion := vac.ResourceVersion
+
+		Expect(testReconcile.apply(context.TODO(), desired.DeepCopy())).NotTo(HaveOccurred())
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "gold"}, &vac)).NotTo(HaveOccurred())
+		Expect(vac.ResourceVersion).Should(Equal(resourceVersion))
+
+		vac.Parameters["iops"] = "100"
+		Expect(testClient.Update(context.TODO(), &vac, client.FieldOwner("other"))).NotTo(HaveOccurred())
+		Expect(testReconcile.apply(context.TODO(), desired.DeepCopy())).NotTo(HaveOccurred())
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "gold"}, &vac)).NotTo(HaveOccurred())
+		Expect(vac.Parameters).Should(HaveKeyWithValue("iops", "5000"))
+	})
+
+	It("should match rendered fields against the current content", func() {
+		desired := map[string]interface{}{"spec": map[string]interface{}{"labels": map[string]interface{}{}, "items": []interface{}{map[string]interface{}{"name": "a"}}}}
+		Expect(renderedFieldsMatch(desired, map[string]interface{}{"spec": map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "a", "default": int64(1)}}}})).Should(BeTrue())
+		Expect(renderedFieldsMatch(desired, map[string]interface{}{"spec": map[string]interface{}{"items": []interface{}{map[string]interface{}{"name": "b"}}}})).Should(BeFalse())
+		Expect(renderedFieldsMatch(desired, map[string]interface{}{"spec": map[string]interface{}{"items": []interface{}{}}})).Should(BeFalse())
+	})
+})
@@ -19,21 +19,33 @@ package tenant
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	groupsnapshotv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 )
 
 const (
@@ -45,18 +57,25 @@ const (
 // TenantReconciler reconciles a Tenant object
 type TenantReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme    *runtime.Scheme
+	Recorder  events.EventRecorder
+	Discovery discovery.DiscoveryInterface
 
 	OverwriteRegistry string
+
+	controller  controller.Controller
+	cache       cache.Cache
+	watchesLock sync.Mutex
+	watches     map[string]bool
 }
 
 //+kubebuilder:rbac:groups=csiprovisioner.kubevirt.io,resources=tenants,verbs=get;list;watch
 //+kubebuilder:rbac:groups=csiprovisioner.kubevirt.io,resources=tenants/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=csiprovisioner.kubevirt.io,resources=tenants/finalizers,verbs=update
-//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=delete
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 
 //+kubebuilder:rbac:groups="",resources=persistentvolumes,verbs="*"
-//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims;persistentvolumeclaims/status,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims;persistentvolumeclaims/status,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;update;patch;create
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=storage.k8s.io,resources=csidrivers,verbs=get;list;watch;update;patch;create
@@ -64,10 +83,18 @@ type TenantReconciler struct {
 //+kubebuilder:rbac:groups=extensions;apps,resources=daemonsets,verbs=get;list;watch;update;patch;create
 //+kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattachments,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattachments/status,verbs=get;list;watch;update;patch
-//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses;,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses;,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshotclasses,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=volumeattributesclasses,verbs=get;list;watch;create;patch;delete
+//+kubebuilder:rbac:groups=groupsnapshot.storage.k8s.io,resources=volumegroupsnapshotclasses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=storage.k8s.io;csi.storage.k8s.io,resources=csinodes;csinodeinfos,verbs=get;list;watch
 //+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs="*"
-//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=create;list
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots;volumesnapshotcontents,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots/status;volumesnapshotcontents/status,verbs=update;patch
+//+kubebuilder:rbac:groups=groupsnapshot.storage.k8s.io,resources=volumegroupsnapshots;volumegroupsnapshotcontents,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=groupsnapshot.storage.k8s.io,resources=volumegroupsnapshots/status;volumegroupsnapshotcontents/status,verbs=update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -85,36 +112,146 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 	objMeta := tenant.GetObjectMeta()
+	original := tenant.DeepCopy()
+	common := getCommonMetadata(&tenant)
 
-	_, err = r.reconcileCSIDriver(ctx, objMeta)
+	err = r.reconcileCSIDriver(ctx, objMeta, common)
 	if err != nil {
 		l.Info("Error reconciling csi driver, requeuing.")
 		return ctrl.Result{}, err
 	}
 
-	_, err = r.reconcileRBAC(ctx, objMeta)
+	err = r.reconcileRBAC(ctx, objMeta, common)
 	if err != nil {
 		l.Info("Error reconciling rbac, requeuing.")
 		return ctrl.Result{}, err
 	}
 
-	_, err = r.reconcileDaemonset(ctx, objMeta, "", "")
+	err = r.reconcileDaemonset(ctx, objMeta, common, "", "")
 	if err != nil {
 		l.Info("Error reconciling daemonset, requeuing.")
 		return ctrl.Result{}, err
 	}
 
-	err = r.reconcileStorageClasses(ctx, objMeta, tenant.Spec.StorageClasses)
+	condition, err := r.reconcileStorageClasses(ctx, objMeta, common, tenant.Spec.StorageClasses)
 	if err != nil {
 		l.Info("Error reconciling storageClass, requeuing.")
 		return ctrl.Result{}, err
 	}
+	r.setCondition(&tenant, condition)
+
+	condition, err = r.reconcileDefaultStorageClass(ctx, &tenant)
+	if err != nil {
+		l.Info("Error reconciling default storageClass, requeuing.")
+		return ctrl.Result{}, err
+	}
+	r.setCondition(&tenant, condition)
+
+	if tenant.Spec.InstallSnapshotController {
+		managed, err := r.reconcileSnapshotCRDs(ctx, common)
+		if err != nil {
+			l.Info("Error reconciling snapshot CRDs, requeuing.")
+			return ctrl.Result{}, err
+		}
+
+		// CRDs installed by someone else come with their own snapshot-controller.
+		if managed {
+			if err := r.reconcileSnapshotController(ctx, objMeta, common); err != nil {
+				l.Info("Error reconciling snapshot controller, requeuing.")
+				return ctrl.Result{}, err
+			}
+		} else {
+			l.Info("Snapshot CRDs are not managed by the operator, skipping snapshot controller.")
+		}
+	}
 
-	err = r.reconcileVolumeSnapshotClasses(ctx, objMeta, tenant.Spec.VolumeSnapshotClasses)
+	result := ctrl.Result{}
+	snapshotAPIAvailable, err := r.hasAPIResource(snapshotv1.SchemeGroupVersion, "volumesnapshotclasses")
 	if err != nil {
-		l.Info("Error reconciling volumeSnapshotClass, requeuing.")
+		l.Info("Error discovering the volumeSnapshot API, requeuing.")
 		return ctrl.Result{}, err
 	}
+	if r.setAPICondition(&tenant, csiprovisionerv1alpha1.ConditionVolumeSnapshotAPIAvailable, snapshotv1.SchemeGroupVersion, "volumesnapshotclasses", snapshotAPIAvailable, len(tenant.Spec.VolumeSnapshotClasses) > 0) {
+		result.RequeueAfter = apiRecheckInterval
+	}
+	if snapshotAPIAvailable {
+		if err := r.ensureWatch("volumesnapshotclasses", &snapshotv1.VolumeSnapshotClass{}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		err = r.reconcileVolumeSnapshotClasses(ctx, objMeta, common, tenant.Spec.VolumeSnapshotClasses)
+		if err != nil {
+			l.Info("Error reconciling volumeSnapshotClass, requeuing.")
+			return ctrl.Result{}, err
+		}
+
+		condition, err = r.reconcileDefaultVolumeSnapshotClass(ctx, &tenant)
+		if err != nil {
+			l.Info("Error reconciling default volumeSnapshotClass, requeuing.")
+			return ctrl.Result{}, err
+		}
+		r.setCondition(&tenant, condition)
+	} else {
+		l.Info("VolumeSnapshot API is not installed, skipping volumeSnapshotClass.")
+	}
+
+	groupSnapshotAPIAvailable, err := r.hasAPIResource(groupsnapshotv1beta2.SchemeGroupVersion, "volumegroupsnapshotclasses")
+	if err != nil {
+		l.Info("Error discovering the volumeGroupSnapshot API, requeuing.")
+		return ctrl.Result{}, err
+	}
+	if r.setAPICondition(&tenant, csiprovisionerv1alpha1.ConditionVolumeGroupSnapshotAPIAvailable, groupsnapshotv1beta2.SchemeGroupVersion, "volumegroupsnapshotclasses", groupSnapshotAPIAvailable, len(tenant.Spec.VolumeGroupSnapshotClasses) > 0) {
+		result.RequeueAfter = apiRecheckInterval
+	}
+	if groupSnapshotAPIAvailable {
+		if err := r.ensureWatch("volumegroupsnapshotclasses", &groupsnapshotv1beta2.VolumeGroupSnapshotClass{}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		err = r.reconcileVolumeGroupSnapshotClasses(ctx, objMeta, common, tenant.Spec.VolumeGroupSnapshotClasses)
+		if err != nil {
+			l.Info("Error reconciling volumeGroupSnapshotClass, requeuing.")
+			return ctrl.Result{}, err
+		}
+
+		condition, err = r.reconcileDefaultVolumeGroupSnapshotClass(ctx, &tenant)
+		if err != nil {
+			l.Info("Error reconciling default volumeGroupSnapshotClass, requeuing.")
+			return ctrl.Result{}, err
+		}
+		r.setCondition(&tenant, condition)
+	} else {
+		l.Info("VolumeGroupSnapshot API is not installed, skipping volumeGroupSnapshotClass.")
+	}
+
+	volumeAttributesClassAPIAvailable, err := r.hasAPIResource(storagev1.SchemeGroupVersion, "volumeattributesclasses")
+	if err != nil {
+		l.Info("Error discovering the volumeAttributesClass API, requeuing.")
+		return ctrl.Result{}, err
+	}
+	if r.setAPICondition(&tenant, csiprovisionerv1alpha1.ConditionVolumeAttributesClassAPIAvailable, storagev1.SchemeGroupVersion, "volumeattributesclasses", volumeAttributesClassAPIAvailable, len(tenant.Spec.VolumeAttributesClasses) > 0) {
+		result.RequeueAfter = apiRecheckInterval
+	}
+	if volumeAttributesClassAPIAvailable {
+		if err := r.ensureWatch("volumeattributesclasses", &storagev1.VolumeAttributesClass{}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		pending, err := r.reconcileVolumeAttributesClasses(ctx, objMeta, common, tenant.Spec.VolumeAttributesClasses)
+		r.setCondition(&tenant, getVolumeAttributesClassCondition(&tenant, pending, err))
+		if err != nil {
+			l.Info("Error reconciling volumeAttributesClass, requeuing.")
+			if statusErr := r.patchStatus(ctx, original, &tenant); statusErr != nil {
+				l.Error(statusErr, "Failed to report volumeAttributesClass error")
+			}
+			return ctrl.Result{}, err
+		}
+		if len(pending) > 0 {
+			result.RequeueAfter = volumeAttributesClassRecreateInterval
+		}
+	} else {
+		l.Info("VolumeAttributesClass API is not served, skipping volumeAttributesClass.")
+	}
 
 	// Cleanup the Deployment that is not removed during migration from non-split to split deployment
 	err = r.Client.Delete(ctx, &appsv1.Deployment{
@@ -126,7 +263,30 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, fmt.Errorf("failed to ensure deployment %s is removed/not present: %w", csiDeploymentName, err)
 	}
 
-	return ctrl.Result{}, nil
+	if err := r.patchStatus(ctx, original, &tenant); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+// setCondition sets the condition on the tenant status and records a warning event if a condition
+// transitioned to False.
+func (r *TenantReconciler) setCondition(tenant *csiprovisionerv1alpha1.Tenant, condition metav1.Condition) {
+	if meta.SetStatusCondition(&tenant.Status.Conditions, condition) && condition.Status == metav1.ConditionFalse {
+		r.Recorder.Eventf(tenant, nil, corev1.EventTypeWarning, condition.Reason, "Reconcile", "%s", condition.Message)
+	}
+}
+
+// patchStatus patches the tenant status if it changed during the reconciliation.
+func (r *TenantReconciler) patchStatus(ctx context.Context, original, tenant *csiprovisionerv1alpha1.Tenant) error {
+	if equality.Semantic.DeepEqual(original.Status, tenant.Status) {
+		return nil
+	}
+	if err := r.Client.Status().Patch(ctx, tenant, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to patch tenant status: %w", err)
+	}
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -137,10 +297,24 @@ func (r *TenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		},
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	r.cache = mgr.GetCache()
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &appsv1.Deployment{}, deploymentNameIndex, indexDeploymentName); err != nil {
+		return err
+	}
+
+	// Watches of optional APIs, like the VolumeSnapshot API, are started in ensureWatch once the API is available.
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&csiprovisionerv1alpha1.Tenant{}, builder.WithPredicates(filterTenants)).
 		Owns(&storagev1.CSIDriver{}).
 		Owns(&appsv1.DaemonSet{}).
-		Owns(&storagev1.StorageClass{}).
-		Complete(r)
+		Owns(&appsv1.Deployment{}).
+		// All StorageClasses are watched to detect conflicting default classes.
+		Watches(&storagev1.StorageClass{}, handler.EnqueueRequestsFromMapFunc(enqueueTenant)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(enqueueTenant), builder.WithPredicates(nodeTopologyChanged)).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	return nil
 }
@@ -22,8 +22,6 @@ import (
 	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
@@ -46,14 +44,11 @@ func getDesiredCSIDriverObj(obj metav1.Object) *storagev1.CSIDriver {
 	}
 }
 
-func (r *TenantReconciler) reconcileCSIDriver(ctx context.Context, obj metav1.Object) (controllerutil.OperationResult, error) {
+func (r *TenantReconciler) reconcileCSIDriver(ctx context.Context, obj metav1.Object, common commonMetadata) error {
 	l := log.FromContext(ctx).WithName("csi-driver")
 	l.Info("Reconciling csi driver", "name", csiDriverName)
 
 	desiredCSIObj := getDesiredCSIDriverObj(obj)
-	currentCSIObj := desiredCSIObj.DeepCopyObject().(*storagev1.CSIDriver)
-	return ctrl.CreateOrUpdate(ctx, r.Client, currentCSIObj, func() error {
-		currentCSIObj.OwnerReferences = desiredCSIObj.OwnerReferences
-		return nil
-	})
+	common.apply(desiredCSIObj)
+	return r.apply(ctx, desiredCSIObj)
 }
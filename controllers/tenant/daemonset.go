@@ -27,8 +27,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
@@ -272,15 +270,12 @@ func getDesiredDaemonSet(obj metav1.Object, imageRegistry, imageRepository, imag
 	}
 }
 
-func (r *TenantReconciler) reconcileDaemonset(ctx context.Context, obj metav1.Object, imageRepository, imageTag string) (controllerutil.OperationResult, error) {
+func (r *TenantReconciler) reconcileDaemonset(ctx context.Context, obj metav1.Object, common commonMetadata, imageRepository, imageTag string) error {
 	l := log.FromContext(ctx).WithName("daemonset")
 	l.Info("Reconciling daemonset", "name", csiDaemonSetName)
 
 	desiredDaemonSetObj := getDesiredDaemonSet(obj, r.OverwriteRegistry, "", "")
-	currentDaemonSetObj := desiredDaemonSetObj.DeepCopyObject().(*appsv1.DaemonSet)
-	return ctrl.CreateOrUpdate(ctx, r.Client, currentDaemonSetObj, func() error {
-		currentDaemonSetObj.OwnerReferences = desiredDaemonSetObj.OwnerReferences
-		currentDaemonSetObj.Spec = desiredDaemonSetObj.Spec
-		return nil
-	})
+	common.apply(desiredDaemonSetObj)
+	common.apply(&desiredDaemonSetObj.Spec.Template)
+	return r.apply(ctx, desiredDaemonSetObj)
 }
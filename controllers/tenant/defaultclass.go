@@ -0,0 +1,211 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	groupsnapshotv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	betaIsDefaultStorageClassAnnotationKey = "storageclass.beta.kubernetes.io/is-default-class"
+
+	reasonDefaultClassUnique      = "Unique"
+	reasonMultipleTenantDefaults  = "MultipleTenantDefaults"
+	reasonConflictingDefaults     = "ConflictingDefaults"
+	reasonDefaultClassDemoted     = "DefaultClassDemoted"
+	reasonDefaultClassDemoteError = "DefaultClassDemoteFailed"
+)
+
+// isDefaultClass reports whether any of the annotations marks the object as default class.
+func isDefaultClass(obj client.Object, annotationKeys ...string) bool {
+	for _, key := range annotationKeys {
+		if obj.GetAnnotations()[key] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// demoteDefaultClass sets the default class annotations of the object to false.
+func (r *TenantReconciler) demoteDefaultClass(ctx context.Context, obj client.Object, annotationKeys ...string) error {
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	for _, key := range annotationKeys {
+		if _, ok := annotations[key]; ok {
+			annotations[key] = "false"
+		}
+	}
+	obj.SetAnnotations(annotations)
+	return r.Client.Patch(ctx, obj, patch)
+}
+
+// reconcileDefaultClasses checks that at most one of the classes is marked as default. If the tenant defines
+// a default class and the policy is DemoteOthers, all other default classes are demoted.
+func (r *TenantReconciler) reconcileDefaultClasses(ctx context.Context, tenant *csiprovisionerv1alpha1.Tenant, kind, conditionType string, classes []client.Object, tenantDefaults []string, policy csiprovisionerv1alpha1.DefaultClassPolicy, annotationKeys ...string) (metav1.Condition, error) {
+	l := log.FromContext(ctx).WithName("defaultClass")
+
+	var otherDefaults []string
+	for _, class := range classes {
+		if !isDefaultClass(class, annotationKeys...) || slices.Contains(tenantDefaults, class.GetName()) {
+			continue
+		}
+
+		if policy == csiprovisionerv1alpha1.DefaultClassPolicyDemoteOthers && len(tenantDefaults) > 0 {
+			l.Info("Demoting default class", "kind", kind, "name", class.GetName())
+			if err := r.demoteDefaultClass(ctx, class, annotationKeys...); err != nil {
+				r.Recorder.Eventf(tenant, nil, corev1.EventTypeWarning, reasonDefaultClassDemoteError, "DemoteDefaultClass", "Failed to demote default %s %s: %v", kind, class.GetName(), err)
+				return metav1.Condition{}, fmt.Errorf("failed to demote default %s %s: %w", kind, class.GetName(), err)
+			}
+			r.Recorder.Eventf(tenant, nil, corev1.EventTypeNormal, reasonDefaultClassDemoted, "DemoteDefaultClass", "Demoted default %s %s in favor of %s", kind, class.GetName(), tenantDefaults[0])
+			continue
+		}
+		otherDefaults = append(otherDefaults, class.GetName())
+	}
+
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: tenant.Generation,
+		Reason:             reasonDefaultClassUnique,
+		Message:            fmt.Sprintf("At most one %s is marked as default", kind),
+	}
+	switch {
+	case len(tenantDefaults) > 1:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonMultipleTenantDefaults
+		condition.Message = fmt.Sprintf("The tenant marks multiple %ss as default: %s", kind, strings.Join(tenantDefaults, ", "))
+	case len(tenantDefaults)+len(otherDefaults) > 1:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonConflictingDefaults
+		condition.Message = fmt.Sprintf("Multiple %ss are marked as default: %s", kind, strings.Join(append(tenantDefaults, otherDefaults...), ", "))
+	}
+	return condition, nil
+}
+
+// getDefaultStorageClassNames returns the names of the StorageClasses the tenant marks as default.
+func getDefaultStorageClassNames(storageClasses []csiprovisionerv1alpha1.StorageClass) []string {
+	var names []string
+	for _, storageClass := range storageClasses {
+		if storageClass.IsDefaultClass == nil || !*storageClass.IsDefaultClass || storageClass.PerZone {
+			continue
+		}
+		name, err := resolveName(storageClass.Name, storageClass.InfraStorageClassName)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (r *TenantReconciler) reconcileDefaultStorageClass(ctx context.Context, tenant *csiprovisionerv1alpha1.Tenant) (metav1.Condition, error) {
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, storageClasses); err != nil {
+		return metav1.Condition{}, fmt.Errorf("failed to list storage classes: %w", err)
+	}
+	classes := make([]client.Object, 0, len(storageClasses.Items))
+	for i := range storageClasses.Items {
+		classes = append(classes, &storageClasses.Items[i])
+	}
+
+	return r.reconcileDefaultClasses(ctx, tenant, "StorageClass", csiprovisionerv1alpha1.ConditionDefaultStorageClassUnique, classes,
+		getDefaultStorageClassNames(tenant.Spec.StorageClasses), tenant.Spec.DefaultStorageClassPolicy,
+		isDefaultStorageClassannotationKey, betaIsDefaultStorageClassAnnotationKey)
+}
+
+// getDefaultVolumeSnapshotClassNames returns the names of the VolumeSnapshotClasses the tenant marks as default.
+func getDefaultVolumeSnapshotClassNames(volumeSnapshotClasses []csiprovisionerv1alpha1.VolumeSnapshotClass) []string {
+	var names []string
+	for _, volumeSnapshotClass := range volumeSnapshotClasses {
+		if volumeSnapshotClass.IsDefaultClass == nil || !*volumeSnapshotClass.IsDefaultClass {
+			continue
+		}
+		name, err := resolveName(volumeSnapshotClass.Name, volumeSnapshotClass.InfraVolumeSnapshotClass)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// reconcileDefaultVolumeSnapshotClass checks the default VolumeSnapshotClasses of the csi.kubevirt.io driver. The
+// snapshot-controller only considers default classes of the driver of the volume.
+func (r *TenantReconciler) reconcileDefaultVolumeSnapshotClass(ctx context.Context, tenant *csiprovisionerv1alpha1.Tenant) (metav1.Condition, error) {
+	volumeSnapshotClasses := &snapshotv1.VolumeSnapshotClassList{}
+	if err := r.Client.List(ctx, volumeSnapshotClasses); err != nil {
+		return metav1.Condition{}, fmt.Errorf("failed to list volume snapshot classes: %w", err)
+	}
+	classes := make([]client.Object, 0, len(volumeSnapshotClasses.Items))
+	for i := range volumeSnapshotClasses.Items {
+		if volumeSnapshotClasses.Items[i].Driver == provisioner {
+			classes = append(classes, &volumeSnapshotClasses.Items[i])
+		}
+	}
+
+	return r.reconcileDefaultClasses(ctx, tenant, "VolumeSnapshotClass", csiprovisionerv1alpha1.ConditionDefaultVolumeSnapshotClassUnique, classes,
+		getDefaultVolumeSnapshotClassNames(tenant.Spec.VolumeSnapshotClasses), tenant.Spec.DefaultVolumeSnapshotClassPolicy,
+		isDefaultVolumeSnapshotClassAnnotationKey)
+}
+
+// getDefaultVolumeGroupSnapshotClassNames returns the names of the VolumeGroupSnapshotClasses the tenant marks as default.
+func getDefaultVolumeGroupSnapshotClassNames(volumeGroupSnapshotClasses []csiprovisionerv1alpha1.VolumeGroupSnapshotClass) []string {
+	var names []string
+	for _, volumeGroupSnapshotClass := range volumeGroupSnapshotClasses {
+		if volumeGroupSnapshotClass.IsDefaultClass == nil || !*volumeGroupSnapshotClass.IsDefaultClass {
+			continue
+		}
+		name, err := resolveName(volumeGroupSnapshotClass.Name, volumeGroupSnapshotClass.InfraVolumeSnapshotClass)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// reconcileDefaultVolumeGroupSnapshotClass checks the default VolumeGroupSnapshotClasses of the csi.kubevirt.io driver.
+func (r *TenantReconciler) reconcileDefaultVolumeGroupSnapshotClass(ctx context.Context, tenant *csiprovisionerv1alpha1.Tenant) (metav1.Condition, error) {
+	volumeGroupSnapshotClasses := &groupsnapshotv1beta2.VolumeGroupSnapshotClassList{}
+	if err := r.Client.List(ctx, volumeGroupSnapshotClasses); err != nil {
+		return metav1.Condition{}, fmt.Errorf("failed to list volume group snapshot classes: %w", err)
+	}
+	classes := make([]client.Object, 0, len(volumeGroupSnapshotClasses.Items))
+	for i := range volumeGroupSnapshotClasses.Items {
+		if volumeGroupSnapshotClasses.Items[i].Driver == provisioner {
+			classes = append(classes, &volumeGroupSnapshotClasses.Items[i])
+		}
+	}
+
+	return r.reconcileDefaultClasses(ctx, tenant, "VolumeGroupSnapshotClass", csiprovisionerv1alpha1.ConditionDefaultVolumeGroupSnapshotClassUnique, classes,
+		getDefaultVolumeGroupSnapshotClassNames(tenant.Spec.VolumeGroupSnapshotClasses), tenant.Spec.DefaultVolumeGroupSnapshotClassPolicy,
+		isDefaultVolumeGroupSnapshotClassAnnotationKey)
+}
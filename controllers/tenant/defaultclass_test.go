@@ -0,0 +1,137 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Reconcile default storageClass", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	var testRecorder *events.FakeRecorder
+	Context("When the default storageClass is reconciled", func() {
+		BeforeEach(func() {
+			testClient = fake.NewClientBuilder().WithObjects(&v1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "foreign",
+					Annotations: map[string]string{isDefaultStorageClassannotationKey: "true"},
+				},
+				Provisioner: "example.com/foreign",
+			}).Build()
+			testRecorder = events.NewFakeRecorder(10)
+			testReconcile = &TenantReconciler{
+				Client:   testClient,
+				Recorder: testRecorder,
+			}
+		})
+
+		It("should report a conflicting foreign default", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", IsDefaultClass: ptr.To(true)}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			condition, err := testReconcile.reconcileDefaultStorageClass(context.TODO(), testTenant)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).Should(Equal(reasonConflictingDefaults))
+
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "foreign"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.Annotations[isDefaultStorageClassannotationKey]).Should(Equal("true"))
+		})
+
+		It("should demote the foreign default", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", IsDefaultClass: ptr.To(true)}})
+			testTenant.Spec.DefaultStorageClassPolicy = v1alpha1.DefaultClassPolicyDemoteOthers
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			condition, err := testReconcile.reconcileDefaultStorageClass(context.TODO(), testTenant)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(testRecorder.Events).Should(HaveLen(1))
+
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "foreign"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.Annotations[isDefaultStorageClassannotationKey]).Should(Equal("false"))
+		})
+
+		It("should report multiple tenant defaults", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", IsDefaultClass: ptr.To(true)}, {InfraStorageClassName: "lvm", IsDefaultClass: ptr.To(true)}})
+			testTenant.Spec.DefaultStorageClassPolicy = v1alpha1.DefaultClassPolicyDemoteOthers
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			condition, err := testReconcile.reconcileDefaultStorageClass(context.TODO(), testTenant)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).Should(Equal(reasonMultipleTenantDefaults))
+		})
+	})
+
+	Context("When the default volumeSnapshotClass is reconciled", func() {
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(snapshotv1.AddToScheme(testScheme)).NotTo(HaveOccurred())
+			testClient = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(
+				&snapshotv1.VolumeSnapshotClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "stale",
+						Annotations: map[string]string{isDefaultVolumeSnapshotClassAnnotationKey: "true"},
+					},
+					Driver: provisioner,
+				},
+				&snapshotv1.VolumeSnapshotClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "other-driver",
+						Annotations: map[string]string{isDefaultVolumeSnapshotClassAnnotationKey: "true"},
+					},
+					Driver: "example.com/foreign",
+				},
+			).Build()
+			testRecorder = events.NewFakeRecorder(10)
+			testReconcile = &TenantReconciler{
+				Client:   testClient,
+				Recorder: testRecorder,
+			}
+		})
+
+		It("should demote stale defaults of the driver only", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeSnapshotClasses = []v1alpha1.VolumeSnapshotClass{{InfraVolumeSnapshotClass: "ceph", IsDefaultClass: ptr.To(true)}}
+			testTenant.Spec.DefaultVolumeSnapshotClassPolicy = v1alpha1.DefaultClassPolicyDemoteOthers
+			Expect(testReconcile.reconcileVolumeSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeSnapshotClasses)).NotTo(HaveOccurred())
+			condition, err := testReconcile.reconcileDefaultVolumeSnapshotClass(context.TODO(), testTenant)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionTrue))
+
+			vsc := snapshotv1.VolumeSnapshotClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "stale"}, &vsc)).NotTo(HaveOccurred())
+			Expect(vsc.Annotations[isDefaultVolumeSnapshotClassAnnotationKey]).Should(Equal("false"))
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "other-driver"}, &vsc)).NotTo(HaveOccurred())
+			Expect(vsc.Annotations[isDefaultVolumeSnapshotClassAnnotationKey]).Should(Equal("true"))
+		})
+	})
+})
@@ -0,0 +1,68 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"maps"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+)
+
+// mergeMetadata sets the desired labels or annotations on the current ones, preserving keys added by others.
+func mergeMetadata(current, desired map[string]string) map[string]string {
+	if len(desired) == 0 {
+		return current
+	}
+	if current == nil {
+		current = make(map[string]string, len(desired))
+	}
+	for key, value := range desired {
+		current[key] = value
+	}
+	return current
+}
+
+const (
+	managedByLabelKey   = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "kubevirt-csi-driver-operator"
+)
+
+// commonMetadata holds the labels and annotations set on every object managed by the operator.
+type commonMetadata struct {
+	labels      map[string]string
+	annotations map[string]string
+}
+
+func getCommonMetadata(tenant *csiprovisionerv1alpha1.Tenant) commonMetadata {
+	labels := maps.Clone(tenant.Spec.CommonLabels)
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[managedByLabelKey] = managedByLabelValue
+	return commonMetadata{
+		labels:      labels,
+		annotations: maps.Clone(tenant.Spec.CommonAnnotations),
+	}
+}
+
+// apply adds the common labels and annotations to the desired object, keeping the values already set on it.
+func (m commonMetadata) apply(desired metav1.Object) {
+	desired.SetLabels(mergeMetadata(maps.Clone(m.labels), desired.GetLabels()))
+	desired.SetAnnotations(mergeMetadata(maps.Clone(m.annotations), desired.GetAnnotations()))
+}
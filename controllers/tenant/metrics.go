@@ -0,0 +1,41 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	writeResultApplied = "applied"
+	writeResultSkipped = "skipped"
+)
+
+// objectWrites counts the writes of managed objects, partitioned by kind and by whether the write was applied or
+// skipped because the desired state didn't change.
+var objectWrites = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kubevirt_csi_operator_object_writes_total",
+		Help: "Number of writes of managed objects, by kind and result (applied or skipped).",
+	},
+	[]string{"kind", "result"},
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(objectWrites)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// invalidNameCharacters matches the runs of characters that aren't allowed in DNS-1123 labels.
+var invalidNameCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+const (
+	generatedNamePrefix = "kubevirt-"
+	nameHashLength      = 8
+)
+
+// resolveName returns the name of a tenant cluster object. A custom name is validated as a DNS-1123
+// subdomain, otherwise the name is generated from the infra cluster object name.
+func resolveName(customName, infraName string) (string, error) {
+	if customName == "" {
+		return generatedName(infraName), nil
+	}
+	if errs := validation.IsDNS1123Subdomain(customName); len(errs) > 0 {
+		return "", fmt.Errorf("invalid name %q: %s", customName, strings.Join(errs, ", "))
+	}
+	return customName, nil
+}
+
+// generatedName returns kubevirt-<infraName>, shortened if needed.
+func generatedName(infraName string) string {
+	return shortenName(generatedNamePrefix + infraName)
+}
+
+// shortenName truncates names exceeding the maximum object name length and suffixes them with a hash of the
+// full name, so that distinct names stay distinct.
+func shortenName(name string) string {
+	if len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+
+	truncated := strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-nameHashLength-1], "-.")
+	return truncated + "-" + nameHash(name)
+}
+
+// zoneNameSuffix returns the zone as DNS-1123 label to suffix the names of per-zone objects with. Characters other
+// than lower case alphanumerics are replaced with dashes, and the name is suffixed with a hash of the zone if it
+// changed, so that zones differing only in replaced characters or in case don't collide.
+func zoneNameSuffix(zone string) string {
+	sanitized := strings.Trim(invalidNameCharacters.ReplaceAllString(strings.ToLower(zone), "-"), "-")
+	switch {
+	case sanitized == zone:
+		return zone
+	case sanitized == "":
+		return nameHash(zone)
+	default:
+		return sanitized + "-" + nameHash(zone)
+	}
+}
+
+// nameHash returns a short hash of the name.
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
@@ -22,8 +22,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
@@ -77,10 +75,9 @@ func getDesiredDaemonsetClusterRole(obj metav1.Object) *rbacv1.ClusterRole {
 	}
 }
 
-func (r *TenantReconciler) reconcileRBAC(ctx context.Context, obj metav1.Object) (map[string]controllerutil.OperationResult, error) {
+func (r *TenantReconciler) reconcileRBAC(ctx context.Context, obj metav1.Object, common commonMetadata) error {
 	l := log.FromContext(ctx).WithName("rbac")
 	l.Info("Reconciling rbac")
-	status := make(map[string]controllerutil.OperationResult)
 
 	// daemonset
 	desiredDaemonsetSa := corev1.ServiceAccount{
@@ -92,27 +89,16 @@ func (r *TenantReconciler) reconcileRBAC(ctx context.Context, obj metav1.Object)
 			},
 		},
 	}
-	currentDaemonsetSa := desiredDaemonsetSa.DeepCopyObject().(*corev1.ServiceAccount)
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, currentDaemonsetSa, func() error {
-		currentDaemonsetSa.OwnerReferences = desiredDaemonsetSa.OwnerReferences
-		return nil
-	})
-	if err != nil {
-		return status, err
+	common.apply(&desiredDaemonsetSa)
+	if err := r.apply(ctx, &desiredDaemonsetSa); err != nil {
+		return err
 	}
-	status["daemonset_service_account"] = op
 
 	desiredDaemonsetCr := getDesiredDaemonsetClusterRole(obj)
-	currentDaemonsetCr := desiredDaemonsetCr.DeepCopyObject().(*rbacv1.ClusterRole)
-	op, err = ctrl.CreateOrUpdate(ctx, r.Client, currentDaemonsetCr, func() error {
-		currentDaemonsetCr.OwnerReferences = desiredDaemonsetCr.OwnerReferences
-		currentDaemonsetCr.Rules = desiredDaemonsetCr.Rules
-		return nil
-	})
-	if err != nil {
-		return status, err
+	common.apply(desiredDaemonsetCr)
+	if err := r.apply(ctx, desiredDaemonsetCr); err != nil {
+		return err
 	}
-	status["daemonset_cluster_role"] = op
 
 	desiredDaemonsetCrb := rbacv1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
@@ -134,17 +120,6 @@ func (r *TenantReconciler) reconcileRBAC(ctx context.Context, obj metav1.Object)
 			Name:     csiDaemonSetName,
 		},
 	}
-	currentDaemonsetCrb := desiredDaemonsetCrb.DeepCopyObject().(*rbacv1.ClusterRoleBinding)
-	op, err = ctrl.CreateOrUpdate(ctx, r.Client, currentDaemonsetCrb, func() error {
-		currentDaemonsetCrb.OwnerReferences = desiredDaemonsetCrb.OwnerReferences
-		currentDaemonsetCrb.Subjects = desiredDaemonsetCrb.Subjects
-		currentDaemonsetCrb.RoleRef = desiredDaemonsetCrb.RoleRef
-		return nil
-	})
-	if err != nil {
-		return status, err
-	}
-	status["daemonset_cluster_role_binding"] = op
-
-	return status, nil
+	common.apply(&desiredDaemonsetCrb)
+	return r.apply(ctx, &desiredDaemonsetCrb)
 }
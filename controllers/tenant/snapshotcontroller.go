@@ -0,0 +1,334 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+
+	"github.com/kubermatic/kubevirt-csi-driver-operator/registry"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+)
+
+const (
+	snapshotControllerName = "snapshot-controller"
+	// deploymentNameIndex indexes Deployments by their name.
+	deploymentNameIndex = "metadata.name"
+	// snapshotterVersion is the external-snapshotter release of the embedded CRDs and the snapshot-controller image.
+	snapshotterVersion = "v8.4.0"
+	// snapshotterVersionAnnotationKey marks the CRDs installed by the operator with the installed release. CRDs
+	// without this annotation were installed by someone else and are left untouched.
+	snapshotterVersionAnnotationKey = "csiprovisioner.kubevirt.io/snapshotter-version"
+)
+
+// snapshotCRDs contains the snapshot.storage.k8s.io CRDs, generated with controller-gen from the external-snapshotter
+// client API types.
+//
+//go:embed manifests/snapshot/*.yaml
+var snapshotCRDs embed.FS
+
+func getDesiredSnapshotCRDs() ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	entries, err := snapshotCRDs.ReadDir("manifests/snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	crds := make([]*apiextensionsv1.CustomResourceDefinition, 0, len(entries))
+	for _, entry := range entries {
+		data, err := snapshotCRDs.ReadFile(path.Join("manifests/snapshot", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.UnmarshalStrict(data, crd); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", entry.Name(), err)
+		}
+		crd.Annotations[snapshotterVersionAnnotationKey] = snapshotterVersion
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+func getDesiredSnapshotControllerClusterRole(obj metav1.Object) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: snapshotControllerName,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"persistentvolumes"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"persistentvolumeclaims"},
+				Verbs:     []string{"get", "list", "watch", "update"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"events"},
+				Verbs:     []string{"list", "watch", "create", "update", "patch"},
+			},
+			{
+				APIGroups: []string{"snapshot.storage.k8s.io"},
+				Resources: []string{"volumesnapshotclasses"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{"snapshot.storage.k8s.io"},
+				Resources: []string{"volumesnapshotcontents"},
+				Verbs:     []string{"create", "get", "list", "watch", "update", "delete", "patch"},
+			},
+			{
+				APIGroups: []string{"snapshot.storage.k8s.io"},
+				Resources: []string{"volumesnapshotcontents/status"},
+				Verbs:     []string{"patch"},
+			},
+			{
+				APIGroups: []string{"snapshot.storage.k8s.io"},
+				Resources: []string{"volumesnapshots"},
+				Verbs:     []string{"get", "list", "watch", "update", "patch", "delete"},
+			},
+			{
+				APIGroups: []string{"snapshot.storage.k8s.io"},
+				Resources: []string{"volumesnapshots/status"},
+				Verbs:     []string{"update", "patch"},
+			},
+			{
+				APIGroups: []string{"groupsnapshot.storage.k8s.io"},
+				Resources: []string{"volumegroupsnapshotclasses"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{"groupsnapshot.storage.k8s.io"},
+				Resources: []string{"volumegroupsnapshotcontents"},
+				Verbs:     []string{"create", "get", "list", "watch", "update", "delete", "patch"},
+			},
+			{
+				APIGroups: []string{"groupsnapshot.storage.k8s.io"},
+				Resources: []string{"volumegroupsnapshotcontents/status"},
+				Verbs:     []string{"patch"},
+			},
+			{
+				APIGroups: []string{"groupsnapshot.storage.k8s.io"},
+				Resources: []string{"volumegroupsnapshots"},
+				Verbs:     []string{"get", "list", "watch", "update", "patch"},
+			},
+			{
+				APIGroups: []string{"groupsnapshot.storage.k8s.io"},
+				Resources: []string{"volumegroupsnapshots/status"},
+				Verbs:     []string{"update", "patch"},
+			},
+			{
+				APIGroups: []string{"coordination.k8s.io"},
+				Resources: []string{"leases"},
+				Verbs:     []string{"get", "watch", "list", "delete", "update", "create"},
+			},
+		},
+	}
+}
+
+func getDesiredSnapshotControllerDeployment(obj metav1.Object, imageRegistry string) *appsv1.Deployment {
+	podLabels := map[string]string{
+		"app": snapshotControllerName,
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotControllerName,
+			Namespace: namespaceName,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](2),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: podLabels,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: snapshotControllerName,
+					PriorityClassName:  "system-cluster-critical",
+					Containers: []corev1.Container{
+						{
+							Name:            snapshotControllerName,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Image:           registry.Must(registry.RewriteImage("registry.k8s.io/sig-storage/snapshot-controller:"+snapshotterVersion, imageRegistry)),
+							Args: []string{
+								"--v=2",
+								"--leader-election=true",
+								"--leader-election-namespace=" + namespaceName,
+							},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("50Mi"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// reconcileSnapshotCRDs installs or upgrades the snapshot.storage.k8s.io CRDs. CRDs installed by someone else are
+// not modified, and no owner reference is set, as deleting the CRDs would delete all snapshots of the cluster. It
+// reports whether all CRDs are managed by the operator.
+func (r *TenantReconciler) reconcileSnapshotCRDs(ctx context.Context, common commonMetadata) (bool, error) {
+	l := log.FromContext(ctx).WithName("snapshot-crds")
+	l.Info("Reconciling snapshot CRDs")
+
+	desiredCRDs, err := getDesiredSnapshotCRDs()
+	if err != nil {
+		return false, fmt.Errorf("failed to load snapshot CRDs: %w", err)
+	}
+
+	managed := true
+	for _, desiredCRD := range desiredCRDs {
+		currentCRD := &apiextensionsv1.CustomResourceDefinition{}
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(desiredCRD), currentCRD)
+		if client.IgnoreNotFound(err) != nil {
+			return false, fmt.Errorf("failed to get CRD %s: %w", desiredCRD.Name, err)
+		}
+		if err == nil && currentCRD.Annotations[snapshotterVersionAnnotationKey] == "" {
+			l.Info("CRD is not managed by the operator, skipping", "name", desiredCRD.Name)
+			managed = false
+			continue
+		}
+
+		common.apply(desiredCRD)
+		if err := r.apply(ctx, desiredCRD); err != nil {
+			return false, fmt.Errorf("failed to apply CRD %s: %w", desiredCRD.Name, err)
+		}
+	}
+	return managed, nil
+}
+
+// indexDeploymentName indexes Deployments by name, so that snapshot-controllers deployed by others are found without
+// going through all Deployments of the cluster.
+func indexDeploymentName(obj client.Object) []string {
+	return []string{obj.GetName()}
+}
+
+// getForeignSnapshotController returns the namespaced name of a snapshot-controller Deployment that was not deployed
+// by the operator, or an empty name if there is none.
+func (r *TenantReconciler) getForeignSnapshotController(ctx context.Context) (string, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.Client.List(ctx, deployments, client.MatchingFields{deploymentNameIndex: snapshotControllerName}); err != nil {
+		return "", fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	for _, deployment := range deployments.Items {
+		if deployment.Namespace != namespaceName {
+			return deployment.Namespace + "/" + deployment.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// reconcileSnapshotController deploys the snapshot-controller and its RBAC, unless a snapshot-controller is already
+// running in the cluster. A second controller would process every snapshot again.
+func (r *TenantReconciler) reconcileSnapshotController(ctx context.Context, obj metav1.Object, common commonMetadata) error {
+	l := log.FromContext(ctx).WithName("snapshot-controller")
+	l.Info("Reconciling snapshot controller", "name", snapshotControllerName)
+
+	foreign, err := r.getForeignSnapshotController(ctx)
+	if err != nil {
+		return err
+	}
+	if foreign != "" {
+		l.Info("Snapshot controller is already deployed, skipping", "deployment", foreign)
+		return nil
+	}
+
+	desiredSa := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotControllerName,
+			Namespace: namespaceName,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+		},
+	}
+	common.apply(&desiredSa)
+	if err := r.apply(ctx, &desiredSa); err != nil {
+		return err
+	}
+
+	desiredCr := getDesiredSnapshotControllerClusterRole(obj)
+	common.apply(desiredCr)
+	if err := r.apply(ctx, desiredCr); err != nil {
+		return err
+	}
+
+	desiredCrb := rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: snapshotControllerName,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      snapshotControllerName,
+				Namespace: namespaceName,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     snapshotControllerName,
+		},
+	}
+	common.apply(&desiredCrb)
+	if err := r.apply(ctx, &desiredCrb); err != nil {
+		return err
+	}
+
+	desiredDeployment := getDesiredSnapshotControllerDeployment(obj, r.OverwriteRegistry)
+	common.apply(desiredDeployment)
+	common.apply(&desiredDeployment.Spec.Template)
+	return r.apply(ctx, desiredDeployment)
+}
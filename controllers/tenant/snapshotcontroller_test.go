@@ -0,0 +1,158 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Reconcile snapshot CRDs", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	Context("When the snapshot CRDs are reconciled", func() {
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(apiextensionsv1.AddToScheme(testScheme)).NotTo(HaveOccurred())
+			testClient = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(&apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "volumesnapshots.snapshot.storage.k8s.io",
+				},
+			}).Build()
+			testReconcile = &TenantReconciler{
+				Client: testClient,
+			}
+		})
+
+		It("should install the missing CRDs and skip foreign ones", func() {
+			Expect(testReconcile.reconcileSnapshotCRDs(context.TODO(), commonMetadata{})).Should(BeFalse())
+
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "volumesnapshotclasses.snapshot.storage.k8s.io"}, crd)).NotTo(HaveOccurred())
+			Expect(crd.Annotations).Should(HaveKeyWithValue(snapshotterVersionAnnotationKey, snapshotterVersion))
+			Expect(crd.Spec.Names.Kind).Should(Equal("VolumeSnapshotClass"))
+
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "volumesnapshotcontents.snapshot.storage.k8s.io"}, crd)).NotTo(HaveOccurred())
+
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "volumesnapshots.snapshot.storage.k8s.io"}, crd)).NotTo(HaveOccurred())
+			Expect(crd.Annotations).ShouldNot(HaveKey(snapshotterVersionAnnotationKey))
+		})
+
+		It("should add the common labels and annotations to the installed CRDs", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.CommonLabels = map[string]string{"team": "platform"}
+			testTenant.Spec.CommonAnnotations = map[string]string{"owner": "tenant-a"}
+			Expect(testReconcile.reconcileSnapshotCRDs(context.TODO(), getCommonMetadata(testTenant))).Should(BeFalse())
+
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "volumesnapshotclasses.snapshot.storage.k8s.io"}, crd)).NotTo(HaveOccurred())
+			Expect(crd.Labels).Should(Equal(map[string]string{"team": "platform", managedByLabelKey: managedByLabelValue}))
+			Expect(crd.Annotations).Should(HaveKeyWithValue("owner", "tenant-a"))
+			Expect(crd.Annotations).Should(HaveKeyWithValue(snapshotterVersionAnnotationKey, snapshotterVersion))
+
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "volumesnapshots.snapshot.storage.k8s.io"}, crd)).NotTo(HaveOccurred())
+			Expect(crd.Labels).Should(BeEmpty())
+		})
+
+		It("should report the CRDs as managed once the operator installed all of them", func() {
+			Expect(testClient.Delete(context.TODO(), &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "volumesnapshots.snapshot.storage.k8s.io"}})).NotTo(HaveOccurred())
+			Expect(testReconcile.reconcileSnapshotCRDs(context.TODO(), commonMetadata{})).Should(BeTrue())
+			Expect(testReconcile.reconcileSnapshotCRDs(context.TODO(), commonMetadata{})).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Reconcile snapshot controller", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	var testTenant *v1alpha1.Tenant
+	BeforeEach(func() {
+		testTenant = createTestTenant(nil)
+		testClient = fake.NewClientBuilder().WithIndex(&appsv1.Deployment{}, deploymentNameIndex, indexDeploymentName).Build()
+		testReconcile = &TenantReconciler{
+			Client: testClient,
+		}
+	})
+
+	It("should deploy the snapshot controller and its RBAC", func() {
+		Expect(testReconcile.reconcileSnapshotController(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{})).NotTo(HaveOccurred())
+
+		sa := &corev1.ServiceAccount{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Namespace: namespaceName, Name: snapshotControllerName}, sa)).NotTo(HaveOccurred())
+		cr := &rbacv1.ClusterRole{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: snapshotControllerName}, cr)).NotTo(HaveOccurred())
+		Expect(cr.Rules).Should(ContainElement(HaveField("Resources", ConsistOf("volumesnapshotcontents"))))
+		Expect(cr.Rules).Should(ContainElement(And(
+			HaveField("APIGroups", ConsistOf("groupsnapshot.storage.k8s.io")),
+			HaveField("Resources", ConsistOf("volumegroupsnapshotcontents")),
+		)))
+		crb := &rbacv1.ClusterRoleBinding{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: snapshotControllerName}, crb)).NotTo(HaveOccurred())
+		Expect(crb.RoleRef.Name).Should(Equal(snapshotControllerName))
+		Expect(crb.Subjects).Should(ConsistOf(rbacv1.Subject{Kind: "ServiceAccount", Name: snapshotControllerName, Namespace: namespaceName}))
+
+		deployment := &appsv1.Deployment{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Namespace: namespaceName, Name: snapshotControllerName}, deployment)).NotTo(HaveOccurred())
+		Expect(deployment.Spec.Template.Spec.ServiceAccountName).Should(Equal(snapshotControllerName))
+		Expect(deployment.Spec.Template.Spec.Containers).Should(HaveLen(1))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("registry.k8s.io/sig-storage/snapshot-controller:" + snapshotterVersion))
+	})
+
+	It("should rewrite the image registry", func() {
+		testReconcile.OverwriteRegistry = "registry.example.com"
+		Expect(testReconcile.reconcileSnapshotController(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{})).NotTo(HaveOccurred())
+
+		deployment := &appsv1.Deployment{}
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Namespace: namespaceName, Name: snapshotControllerName}, deployment)).NotTo(HaveOccurred())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("registry.example.com/sig-storage/snapshot-controller:" + snapshotterVersion))
+	})
+
+	It("should keep deploying the snapshot controller it already deployed", func() {
+		Expect(testReconcile.reconcileSnapshotController(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{})).NotTo(HaveOccurred())
+		Expect(testReconcile.getForeignSnapshotController(context.TODO())).Should(BeEmpty())
+	})
+
+	It("should skip the snapshot controller if one is already running", func() {
+		Expect(testClient.Create(context.TODO(), &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "snapshot-controller"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "controller", Image: "registry.k8s.io/sig-storage/snapshot-controller:v8.2.0"}}},
+				},
+			},
+		})).NotTo(HaveOccurred())
+		Expect(testReconcile.reconcileSnapshotController(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{})).NotTo(HaveOccurred())
+
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Namespace: namespaceName, Name: snapshotControllerName}, &corev1.ServiceAccount{})).Should(Satisfy(apierrors.IsNotFound))
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: snapshotControllerName}, &rbacv1.ClusterRole{})).Should(Satisfy(apierrors.IsNotFound))
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: snapshotControllerName}, &rbacv1.ClusterRoleBinding{})).Should(Satisfy(apierrors.IsNotFound))
+		Expect(testClient.Get(context.TODO(), client.ObjectKey{Namespace: namespaceName, Name: snapshotControllerName}, &appsv1.Deployment{})).Should(Satisfy(apierrors.IsNotFound))
+	})
+})
@@ -19,27 +19,122 @@ package tenant
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 
 	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/utils/ptr"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
 	provisioner                        = "csi.kubevirt.io"
 	isDefaultStorageClassannotationKey = "storageclass.kubernetes.io/is-default-class"
+
+	// perZoneStorageClassLabelKey marks the StorageClasses created for a single zone of a perZone StorageClass.
+	perZoneStorageClassLabelKey = "csiprovisioner.kubevirt.io/per-zone"
+
+	infraStorageClassNameParameterKey = "infraStorageClassName"
+	busParameterKey                   = "bus"
+	fsTypeParameterKey                = "csi.storage.k8s.io/fstype"
+
+	reasonStorageClassesUpToDate = "UpToDate"
+	reasonStorageClassesOutdated = "Outdated"
 )
 
-func getDesiredStorageClass(obj metav1.Object, storageClass csiprovisionerv1alpha1.StorageClass) *storagev1.StorageClass {
+// getStorageClassParameters merges the user provided parameters with the parameters derived from the
+// StorageClass fields. Reserved keys cannot be overridden.
+func getStorageClassParameters(storageClass csiprovisionerv1alpha1.StorageClass) (map[string]string, error) {
+	parameters := make(map[string]string, len(storageClass.Parameters)+3)
+	for key, value := range storageClass.Parameters {
+		if key == infraStorageClassNameParameterKey || key == busParameterKey {
+			return nil, fmt.Errorf("parameter %s is reserved", key)
+		}
+		parameters[key] = value
+	}
+
+	if storageClass.FsType != "" {
+		if value, ok := parameters[fsTypeParameterKey]; ok && value != storageClass.FsType {
+			return nil, fmt.Errorf("parameter %s conflicts with fsType %s", fsTypeParameterKey, storageClass.FsType)
+		}
+		parameters[fsTypeParameterKey] = storageClass.FsType
+	}
+
+	parameters[infraStorageClassNameParameterKey] = storageClass.InfraStorageClassName
+	parameters[busParameterKey] = storageClass.Bus
+
+	return parameters, nil
+}
+
+// getAllowedTopologies returns the topology terms derived from the nodes, the explicitly configured topology terms,
+// or a single term requiring both the configured zones and regions. Separate terms would be ORed and allow nodes
+// matching only one of them.
+func getAllowedTopologies(storageClass csiprovisionerv1alpha1.StorageClass, nodes []corev1.Node) ([]corev1.TopologySelectorTerm, error) {
+	if storageClass.TopologyFromNodes != nil {
+		if len(storageClass.AllowedTopologies) > 0 || len(storageClass.Zones) > 0 || len(storageClass.Regions) > 0 {
+			return nil, fmt.Errorf("topologyFromNodes is mutually exclusive with allowedTopologies, zones and regions")
+		}
+		topologies, err := getNodeTopologies(nodes, storageClass.TopologyFromNodes)
+		if err != nil {
+			return nil, err
+		}
+		return getNodeTopologySelectorTerms(topologies), nil
+	}
+
+	if len(storageClass.AllowedTopologies) > 0 {
+		if len(storageClass.Zones) > 0 || len(storageClass.Regions) > 0 {
+			return nil, fmt.Errorf("allowedTopologies is mutually exclusive with zones and regions")
+		}
+		return storageClass.AllowedTopologies, nil
+	}
+
+	var requirements []corev1.TopologySelectorLabelRequirement
+	if len(storageClass.Zones) > 0 {
+		requirements = append(requirements, corev1.TopologySelectorLabelRequirement{
+			Key:    corev1.LabelTopologyZone,
+			Values: storageClass.Zones,
+		})
+	}
+	if len(storageClass.Regions) > 0 {
+		requirements = append(requirements, corev1.TopologySelectorLabelRequirement{
+			Key:    corev1.LabelTopologyRegion,
+			Values: storageClass.Regions,
+		})
+	}
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+
+	return []corev1.TopologySelectorTerm{{MatchLabelExpressions: requirements}}, nil
+}
+
+func getDesiredStorageClass(obj metav1.Object, storageClass csiprovisionerv1alpha1.StorageClass, nodes []corev1.Node) (*storagev1.StorageClass, error) {
+	name, err := resolveName(storageClass.Name, storageClass.InfraStorageClassName)
+	if err != nil {
+		return nil, fmt.Errorf("storage class for infra storage class %s: %w", storageClass.InfraStorageClassName, err)
+	}
+	parameters, err := getStorageClassParameters(storageClass)
+	if err != nil {
+		return nil, fmt.Errorf("storage class %s: %w", name, err)
+	}
+	allowedTopologies, err := getAllowedTopologies(storageClass, nodes)
+	if err != nil {
+		return nil, fmt.Errorf("storage class %s: %w", name, err)
+	}
+
 	sc := &storagev1.StorageClass{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("kubevirt-%s", storageClass.InfraStorageClassName),
+			Name: name,
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
 			},
@@ -48,62 +143,260 @@ func getDesiredStorageClass(obj metav1.Object, storageClass csiprovisionerv1alph
 			},
 			Labels: storageClass.Labels,
 		},
-		Provisioner: provisioner,
-		Parameters: map[string]string{
-			"infraStorageClassName": storageClass.InfraStorageClassName,
-			"bus":                   storageClass.Bus,
-		},
+		Provisioner:          provisioner,
+		Parameters:           parameters,
+		MountOptions:         storageClass.MountOptions,
 		VolumeBindingMode:    storageClass.VolumeBindingMode,
 		ReclaimPolicy:        ptr.To(corev1.PersistentVolumeReclaimPolicy(storageClass.ReclaimPolicy)),
 		AllowVolumeExpansion: ptr.To(storageClass.AllowVolumeExpansion),
+		AllowedTopologies:    allowedTopologies,
 	}
 
-	var allowedTopologies []corev1.TopologySelectorTerm
-	if len(storageClass.Zones) > 0 {
-		allowedTopology := corev1.TopologySelectorTerm{
-			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{
-				{
-					Key:    "topology.kubernetes.io/zone",
-					Values: storageClass.Zones,
-				},
-			},
+	return sc, nil
+}
+
+// getDesiredStorageClasses returns the desired StorageClass, or one StorageClass per zone if perZone is set. Zones that
+// aren't valid label values are skipped and reported in the returned messages.
+func getDesiredStorageClasses(obj metav1.Object, storageClass csiprovisionerv1alpha1.StorageClass, nodes []corev1.Node) ([]*storagev1.StorageClass, []string, error) {
+	if !storageClass.PerZone {
+		sc, err := getDesiredStorageClass(obj, storageClass, nodes)
+		if err != nil {
+			return nil, nil, err
 		}
-		allowedTopologies = append(allowedTopologies, allowedTopology)
+		return []*storagev1.StorageClass{sc}, nil, nil
 	}
 
-	if len(storageClass.Regions) > 0 {
-		allowedTopology := corev1.TopologySelectorTerm{
-			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{
-				{
-					Key:    "topology.kubernetes.io/region",
-					Values: storageClass.Regions,
-				},
-			},
+	baseName, err := resolveName(storageClass.Name, storageClass.InfraStorageClassName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage class for infra storage class %s: %w", storageClass.InfraStorageClassName, err)
+	}
+	if len(storageClass.Zones) == 0 && storageClass.TopologyFromNodes == nil {
+		return nil, nil, fmt.Errorf("storage class %s: perZone requires zones or topologyFromNodes", baseName)
+	}
+	if storageClass.IsDefaultClass != nil && *storageClass.IsDefaultClass {
+		return nil, nil, fmt.Errorf("storage class %s: perZone storage classes can't be the default class", baseName)
+	}
+	zoneRegions, err := getZoneRegions(storageClass, nodes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage class %s: %w", baseName, err)
+	}
+	zones := slices.Sorted(maps.Keys(zoneRegions))
+
+	storageClasses := make([]*storagev1.StorageClass, 0, len(zones))
+	var skipped []string
+	for _, zone := range zones {
+		if errs := validation.IsValidLabelValue(zone); len(errs) > 0 {
+			skipped = append(skipped, fmt.Sprintf("skipping invalid zone %q of storage class %s: %s", zone, baseName, strings.Join(errs, ", ")))
+			continue
+		}
+		zoneStorageClass := storageClass
+		zoneStorageClass.Name = shortenName(baseName + "-" + zoneNameSuffix(zone))
+		zoneStorageClass.Zones = []string{zone}
+		zoneStorageClass.Regions = zoneRegions[zone]
+		zoneStorageClass.TopologyFromNodes = nil
+		zoneStorageClass.Labels = maps.Clone(storageClass.Labels)
+		if zoneStorageClass.Labels == nil {
+			zoneStorageClass.Labels = make(map[string]string)
 		}
-		allowedTopologies = append(allowedTopologies, allowedTopology)
+		zoneStorageClass.Labels[perZoneStorageClassLabelKey] = "true"
+
+		sc, err := getDesiredStorageClass(obj, zoneStorageClass, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		storageClasses = append(storageClasses, sc)
 	}
+	return storageClasses, skipped, nil
+}
 
-	sc.AllowedTopologies = allowedTopologies
+// pruneZoneStorageClasses deletes the per-zone StorageClasses of the tenant that are no longer desired.
+func (r *TenantReconciler) pruneZoneStorageClasses(ctx context.Context, obj metav1.Object, desiredNames map[string]bool) error {
+	storageClasses := &storagev1.StorageClassList{}
+	if err := r.Client.List(ctx, storageClasses, client.HasLabels{perZoneStorageClassLabelKey}); err != nil {
+		return fmt.Errorf("failed to list per-zone storage classes: %w", err)
+	}
+	for i := range storageClasses.Items {
+		sc := &storageClasses.Items[i]
+		if desiredNames[sc.Name] || !metav1.IsControlledBy(sc, obj) {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting storageClass of removed zone", "name", sc.Name)
+		if err := r.Client.Delete(ctx, sc); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete storage class %s: %w", sc.Name, err)
+		}
+	}
+	return nil
+}
+
+// storageClassImmutableFieldsDiffer reports whether the immutable fields of the current StorageClass, except for the
+// allowed topologies, differ from the desired ones.
+func storageClassImmutableFieldsDiffer(current, desired *storagev1.StorageClass) bool {
+	defaultBindingMode := storagev1.VolumeBindingImmediate
+	reclaimPolicy := func(sc *storagev1.StorageClass) corev1.PersistentVolumeReclaimPolicy {
+		if sc.ReclaimPolicy == nil || *sc.ReclaimPolicy == "" {
+			return corev1.PersistentVolumeReclaimDelete
+		}
+		return *sc.ReclaimPolicy
+	}
+	return current.Provisioner != desired.Provisioner ||
+		!equality.Semantic.DeepEqual(current.Parameters, desired.Parameters) ||
+		!equality.Semantic.DeepEqual(current.MountOptions, desired.MountOptions) ||
+		ptr.Deref(current.VolumeBindingMode, defaultBindingMode) != ptr.Deref(desired.VolumeBindingMode, defaultBindingMode) ||
+		reclaimPolicy(current) != reclaimPolicy(desired)
+}
+
+// keepImmutableFields sets the immutable fields of the desired StorageClass to the current ones, so that only its
+// mutable fields are applied.
+func keepImmutableFields(current, desired *storagev1.StorageClass) {
+	desired.Provisioner = current.Provisioner
+	desired.Parameters = current.Parameters
+	desired.MountOptions = current.MountOptions
+	desired.VolumeBindingMode = current.VolumeBindingMode
+	desired.ReclaimPolicy = current.ReclaimPolicy
+	desired.AllowedTopologies = current.AllowedTopologies
+}
+
+// recreateStorageClassIfNeeded deletes a StorageClass owned by the tenant whose allowed topologies derived from nodes
+// are outdated, so that it gets created again with the desired state. Existing volumes are not affected by the
+// deletion. Other StorageClasses are never deleted, as that would leave the cluster without the class, or without a
+// default class, until it is created again. Their outdated immutable fields are kept and reported in the returned
+// message instead.
+func (r *TenantReconciler) recreateStorageClassIfNeeded(ctx context.Context, obj metav1.Object, desired *storagev1.StorageClass, topologyFromNodes bool) (string, error) {
+	current := &storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	topologyDiffers := !equality.Semantic.DeepEqual(current.AllowedTopologies, desired.AllowedTopologies)
+	immutableFieldsDiffer := storageClassImmutableFieldsDiffer(current, desired)
+	if !topologyDiffers && !immutableFieldsDiffer {
+		return "", nil
+	}
+	if !metav1.IsControlledBy(current, obj) {
+		return "", fmt.Errorf("storage class %s is not managed by tenant %s and its immutable fields differ", current.Name, obj.GetName())
+	}
+
+	if topologyFromNodes && !immutableFieldsDiffer {
+		log.FromContext(ctx).Info("Recreating storageClass to update allowed topologies", "name", current.Name)
+		if err := r.Client.Delete(ctx, current, client.Preconditions{UID: &current.UID}); err != nil && !apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to delete storage class %s: %w", current.Name, err)
+		}
+		return "", nil
+	}
+
+	keepImmutableFields(current, desired)
+	return fmt.Sprintf("storage class %s has outdated immutable fields, delete it to recreate it with the desired ones", current.Name), nil
+}
+
+// keepAllowedTopologies sets the allowed topologies of the desired StorageClass to those of the existing one, if any.
+func (r *TenantReconciler) keepAllowedTopologies(ctx context.Context, desired *storagev1.StorageClass) error {
+	current := &storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	desired.AllowedTopologies = current.AllowedTopologies
+	return nil
+}
+
+// getStorageClassCondition returns the condition reporting the StorageClasses that don't match their desired state.
+func getStorageClassCondition(obj metav1.Object, outdated []string) metav1.Condition {
+	if len(outdated) > 0 {
+		return metav1.Condition{
+			Type:               csiprovisionerv1alpha1.ConditionStorageClassesUpToDate,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: obj.GetGeneration(),
+			Reason:             reasonStorageClassesOutdated,
+			Message:            strings.Join(outdated, "; "),
+		}
+	}
+	return metav1.Condition{
+		Type:               csiprovisionerv1alpha1.ConditionStorageClassesUpToDate,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: obj.GetGeneration(),
+		Reason:             reasonStorageClassesUpToDate,
+		Message:            "The StorageClasses are up to date",
+	}
+}
 
-	return sc
+func usesTopologyFromNodes(storageClasses []csiprovisionerv1alpha1.StorageClass) bool {
+	for _, storageClass := range storageClasses {
+		if storageClass.TopologyFromNodes != nil {
+			return true
+		}
+	}
+	return false
 }
 
-func (r *TenantReconciler) reconcileStorageClasses(ctx context.Context, obj metav1.Object, storageClasses []csiprovisionerv1alpha1.StorageClass) error {
+// reconcileStorageClasses creates the StorageClasses of the tenant and returns the condition reporting those that
+// don't match their desired state.
+func (r *TenantReconciler) reconcileStorageClasses(ctx context.Context, obj metav1.Object, common commonMetadata, storageClasses []csiprovisionerv1alpha1.StorageClass) (metav1.Condition, error) {
 	l := log.FromContext(ctx).WithName("storageClass")
 	l.Info("Reconciling storageClass")
+
+	var nodes []corev1.Node
+	if usesTopologyFromNodes(storageClasses) {
+		nodeList := &corev1.NodeList{}
+		if err := r.Client.List(ctx, nodeList); err != nil {
+			return metav1.Condition{}, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		nodes = nodeList.Items
+	}
+
+	var outdated []string
+	keepZoneStorageClasses := false
+	names := make(map[string]bool, len(storageClasses))
 	for _, storageClass := range storageClasses {
-		desiredStorageClass := getDesiredStorageClass(obj, storageClass)
-		currentStorageClass := desiredStorageClass.DeepCopyObject().(*storagev1.StorageClass)
-		if _, err := ctrl.CreateOrUpdate(ctx, r.Client, currentStorageClass, func() error {
-			currentStorageClass.Annotations = desiredStorageClass.Annotations
-			currentStorageClass.OwnerReferences = desiredStorageClass.OwnerReferences
-			currentStorageClass.Parameters = desiredStorageClass.Parameters
-			currentStorageClass.VolumeBindingMode = desiredStorageClass.VolumeBindingMode
-			return nil
+		desiredStorageClasses, skipped, err := getDesiredStorageClasses(obj, storageClass, nodes)
+		if err != nil {
+			return metav1.Condition{}, err
+		}
+		outdated = append(outdated, skipped...)
 
-		}); err != nil {
-			return err
+		// Without any node topology, the derived allowed topologies would be empty, which allows all topologies.
+		// The existing classes are kept until nodes with topology labels show up.
+		noNodeTopology := storageClass.TopologyFromNodes != nil &&
+			(len(desiredStorageClasses) == 0 || !storageClass.PerZone && len(desiredStorageClasses[0].AllowedTopologies) == 0)
+		if noNodeTopology {
+			name, _ := resolveName(storageClass.Name, storageClass.InfraStorageClassName)
+			outdated = append(outdated, fmt.Sprintf("no node topology found for storage class %s, keeping its allowed topologies", name))
+			keepZoneStorageClasses = keepZoneStorageClasses || storageClass.PerZone
+		}
+
+		for _, desiredStorageClass := range desiredStorageClasses {
+			if names[desiredStorageClass.Name] {
+				// A zone whose class collides with another class only fails that zone, the other classes are still
+				// reconciled.
+				if storageClass.PerZone {
+					outdated = append(outdated, fmt.Sprintf("skipping per-zone storage class %s, the name is already used", desiredStorageClass.Name))
+					continue
+				}
+				return metav1.Condition{}, fmt.Errorf("duplicate storage class name %s", desiredStorageClass.Name)
+			}
+			names[desiredStorageClass.Name] = true
+
+			common.apply(desiredStorageClass)
+			if noNodeTopology {
+				if err := r.keepAllowedTopologies(ctx, desiredStorageClass); err != nil {
+					return metav1.Condition{}, err
+				}
+			}
+			message, err := r.recreateStorageClassIfNeeded(ctx, obj, desiredStorageClass, storageClass.TopologyFromNodes != nil)
+			if err != nil {
+				return metav1.Condition{}, err
+			}
+			if message != "" {
+				outdated = append(outdated, message)
+			}
+
+			if err := r.apply(ctx, desiredStorageClass); err != nil {
+				return metav1.Condition{}, fmt.Errorf("failed to apply storage class %s: %w", desiredStorageClass.Name, err)
+			}
 		}
 	}
-	return nil
+
+	if !keepZoneStorageClasses {
+		if err := r.pruneZoneStorageClasses(ctx, obj, names); err != nil {
+			return metav1.Condition{}, err
+		}
+	}
+	return getStorageClassCondition(obj, outdated), nil
 }
@@ -19,15 +19,22 @@ package tenant
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 var _ = Describe("Reconcile storageClass", func() {
@@ -43,19 +50,218 @@ var _ = Describe("Reconcile storageClass", func() {
 
 		It("should get created", func() {
 			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "test-local-path-1", Bus: "scsi", Zones: []string{"r1a", "r2a"}, Regions: []string{"r1", "r2"}}, {InfraStorageClassName: "test-local-path-2", Bus: "scsi"}})
-			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), testTenant.Spec.StorageClasses)).NotTo(HaveOccurred())
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
 			scList := v1.StorageClassList{}
 			Expect(testClient.List(context.TODO(), &scList)).NotTo(HaveOccurred())
 			Expect(len(scList.Items)).Should(Equal(2))
 		})
 
-		It("should return an error in case of CreateOrUpdate failure", func() {
+		It("should merge the common labels and annotations", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Bus: "scsi", Labels: map[string]string{"team": "storage"}}})
+			testTenant.Spec.CommonLabels = map[string]string{"team": "platform", "cost-center": "42"}
+			testTenant.Spec.CommonAnnotations = map[string]string{"owner": "tenant-a"}
+			testRecorder := events.NewFakeRecorder(10)
+			testReconcile.Recorder = testRecorder
+			Expect(testClient.Create(context.TODO(), &v1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "kubevirt-ceph",
+					Labels:      map[string]string{"foreign": "true"},
+					Annotations: map[string]string{"foreign": "true"},
+				},
+				Provisioner:   provisioner,
+				Parameters:    map[string]string{"infraStorageClassName": "ceph", "bus": "scsi"},
+				ReclaimPolicy: ptr.To(corev1.PersistentVolumeReclaimDelete),
+			})).NotTo(HaveOccurred())
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), getCommonMetadata(testTenant), testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.Labels).Should(Equal(map[string]string{"foreign": "true", "team": "storage", "cost-center": "42", managedByLabelKey: managedByLabelValue}))
+			Expect(sc.Annotations).Should(HaveKeyWithValue("foreign", "true"))
+			Expect(sc.Annotations).Should(HaveKeyWithValue("owner", "tenant-a"))
+			Expect(testRecorder.Events).Should(Receive(ContainSubstring(reasonFieldConflict)))
+		})
+
+		It("should use the custom name if set", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{Name: "fast", InfraStorageClassName: "ceph", Bus: "scsi"}, {Name: "fast-virtio", InfraStorageClassName: "ceph", Bus: "virtio"}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "fast"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.Parameters["bus"]).Should(Equal("scsi"))
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "fast-virtio"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.Parameters["bus"]).Should(Equal("virtio"))
+		})
+
+		It("should truncate long generated names", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: strings.Repeat("a", 250), Bus: "scsi"}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			scList := v1.StorageClassList{}
+			Expect(testClient.List(context.TODO(), &scList)).NotTo(HaveOccurred())
+			Expect(len(scList.Items)).Should(Equal(1))
+			Expect(len(scList.Items[0].Name)).Should(Equal(253))
+			Expect(scList.Items[0].Name).Should(HavePrefix("kubevirt-aaa"))
+		})
+
+		It("should return an error for an invalid name", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{Name: "Invalid_Name", InfraStorageClassName: "ceph"}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().To(HaveOccurred())
+		})
+
+		It("should return an error for duplicate names", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Bus: "scsi"}, {InfraStorageClassName: "ceph", Bus: "virtio"}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().To(HaveOccurred())
+		})
+
+		It("should set parameters, fsType and mountOptions", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Bus: "scsi", FsType: "xfs", Parameters: map[string]string{"foo": "bar"}, MountOptions: []string{"discard", "noatime"}}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.Parameters).Should(Equal(map[string]string{
+				"infraStorageClassName":     "ceph",
+				"bus":                       "scsi",
+				"csi.storage.k8s.io/fstype": "xfs",
+				"foo":                       "bar",
+			}))
+			Expect(sc.MountOptions).Should(Equal([]string{"discard", "noatime"}))
+		})
+
+		It("should return an error if a reserved parameter is set", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Parameters: map[string]string{"bus": "virtio"}}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().To(HaveOccurred())
+		})
+
+		It("should combine zones and regions into a single topology term", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Zones: []string{"a"}, Regions: []string{"r2"}}})
+			sc, err := getDesiredStorageClass(testTenant.GetObjectMeta(), testTenant.Spec.StorageClasses[0], nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sc.AllowedTopologies).Should(Equal([]corev1.TopologySelectorTerm{{
+				MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{
+					{Key: "topology.kubernetes.io/zone", Values: []string{"a"}},
+					{Key: "topology.kubernetes.io/region", Values: []string{"r2"}},
+				},
+			}}))
+		})
+
+		It("should use custom allowedTopologies", func() {
+			allowedTopologies := []corev1.TopologySelectorTerm{
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "example.com/rack", Values: []string{"r1"}}}},
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "example.com/rack", Values: []string{"r2"}}}},
+			}
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", AllowedTopologies: allowedTopologies}})
+			sc, err := getDesiredStorageClass(testTenant.GetObjectMeta(), testTenant.Spec.StorageClasses[0], nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sc.AllowedTopologies).Should(Equal(allowedTopologies))
+		})
+
+		It("should return an error if allowedTopologies is combined with zones", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Zones: []string{"a"}, AllowedTopologies: []corev1.TopologySelectorTerm{{}}}})
+			_, err := getDesiredStorageClass(testTenant.GetObjectMeta(), testTenant.Spec.StorageClasses[0], nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should derive allowedTopologies from nodes and recreate the storageClass when they change", func() {
+			Expect(testClient.Create(context.TODO(), createTestNode("node-1", "a", "r1", nil))).NotTo(HaveOccurred())
+			Expect(testClient.Create(context.TODO(), createTestNode("node-2", "b", "r1", map[string]string{"pool": "other"}))).NotTo(HaveOccurred())
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", TopologyFromNodes: &v1alpha1.TopologyFromNodes{
+				NodeSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "pool", Operator: metav1.LabelSelectorOpDoesNotExist}}},
+			}}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.AllowedTopologies).Should(Equal([]corev1.TopologySelectorTerm{
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "topology.kubernetes.io/zone", Values: []string{"a"}}, {Key: "topology.kubernetes.io/region", Values: []string{"r1"}}}},
+			}))
+
+			Expect(testClient.Create(context.TODO(), createTestNode("node-3", "c", "r2", nil))).NotTo(HaveOccurred())
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.AllowedTopologies).Should(Equal([]corev1.TopologySelectorTerm{
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "topology.kubernetes.io/zone", Values: []string{"a"}}, {Key: "topology.kubernetes.io/region", Values: []string{"r1"}}}},
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "topology.kubernetes.io/zone", Values: []string{"c"}}, {Key: "topology.kubernetes.io/region", Values: []string{"r2"}}}},
+			}))
+		})
+
+		It("should only react to node topology label changes", func() {
+			oldNode := createTestNode("node-1", "a", "r1", nil)
+			Expect(nodeTopologyChanged.Update(event.UpdateEvent{ObjectOld: oldNode, ObjectNew: createTestNode("node-1", "a", "r1", map[string]string{"pool": "other"})})).Should(BeFalse())
+			Expect(nodeTopologyChanged.Update(event.UpdateEvent{ObjectOld: oldNode, ObjectNew: createTestNode("node-1", "b", "r1", nil)})).Should(BeTrue())
+			Expect(nodeTopologyChanged.Update(event.UpdateEvent{ObjectOld: oldNode, ObjectNew: createTestNode("node-1", "a", "r2", nil)})).Should(BeTrue())
+		})
+
+		It("should keep the allowedTopologies derived from nodes if no node topology is found", func() {
+			Expect(testClient.Create(context.TODO(), createTestNode("node-1", "a", "r1", nil))).NotTo(HaveOccurred())
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", TopologyFromNodes: &v1alpha1.TopologyFromNodes{}}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+
+			Expect(testClient.Delete(context.TODO(), createTestNode("node-1", "a", "r1", nil))).NotTo(HaveOccurred())
+			condition, err := testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.AllowedTopologies).Should(HaveLen(1))
+		})
+
+		It("should keep the immutable fields of storageClasses not using topologyFromNodes", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Zones: []string{"a"}}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			uid := sc.UID
+
+			testTenant.Spec.StorageClasses[0].Zones = []string{"b"}
+			testTenant.Spec.StorageClasses[0].AllowVolumeExpansion = true
+			condition, err := testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).Should(Equal(reasonStorageClassesOutdated))
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.UID).Should(Equal(uid))
+			Expect(sc.AllowedTopologies[0].MatchLabelExpressions[0].Values).Should(Equal([]string{"a"}))
+			Expect(*sc.AllowVolumeExpansion).Should(BeTrue())
+		})
+
+		It("should create one storageClass per zone and prune removed zones", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Zones: []string{"zone-a", "zone-b"}, PerZone: true}})
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			sc := v1.StorageClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph-zone-a"}, &sc)).NotTo(HaveOccurred())
+			Expect(sc.AllowedTopologies).Should(Equal([]corev1.TopologySelectorTerm{
+				{MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: "topology.kubernetes.io/zone", Values: []string{"zone-a"}}}},
+			}))
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph-zone-b"}, &sc)).NotTo(HaveOccurred())
+
+			testTenant.Spec.StorageClasses[0].Zones = []string{"zone-a"}
+			Expect(testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)).Error().NotTo(HaveOccurred())
+			scList := v1.StorageClassList{}
+			Expect(testClient.List(context.TODO(), &scList)).NotTo(HaveOccurred())
+			Expect(len(scList.Items)).Should(Equal(1))
+			Expect(scList.Items[0].Name).Should(Equal("kubevirt-ceph-zone-a"))
+		})
+
+		It("should derive distinct valid names from zones and report invalid zones", func() {
+			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "ceph", Zones: []string{"Zone_A", "zone_a", "a_.b", "bad zone"}, PerZone: true}})
+			condition, err := testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Message).Should(ContainSubstring(`invalid zone "bad zone"`))
+
+			scList := v1.StorageClassList{}
+			Expect(testClient.List(context.TODO(), &scList)).NotTo(HaveOccurred())
+			Expect(scList.Items).Should(HaveLen(3))
+			for _, sc := range scList.Items {
+				Expect(validation.IsDNS1123Subdomain(sc.Name)).Should(BeEmpty())
+				Expect(sc.Name).Should(HavePrefix("kubevirt-ceph-"))
+			}
+		})
+
+		It("should return an error in case of apply failure", func() {
 			testTenant := createTestTenant([]v1alpha1.StorageClass{{InfraStorageClassName: "test-local-path-1", Bus: "scsi"}})
 			testReconcile.Client = &fakeClientWithError{
 				Client:        testClient,
 				generateError: true,
 			}
-			err := testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), testTenant.Spec.StorageClasses)
+			_, err := testReconcile.reconcileStorageClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.StorageClasses)
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -83,3 +289,21 @@ func (fake *fakeClientWithError) Create(ctx context.Context, obj client.Object,
 	}
 	return fake.Client.Create(ctx, obj, opts...)
 }
+
+func (fake *fakeClientWithError) Apply(ctx context.Context, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+	if fake.generateError {
+		return errors.New("error applying storageClass")
+	}
+	return fake.Client.Apply(ctx, obj, opts...)
+}
+
+func createTestNode(name, zone, region string, extraLabels map[string]string) *corev1.Node {
+	nodeLabels := map[string]string{
+		"topology.kubernetes.io/zone":   zone,
+		"topology.kubernetes.io/region": region,
+	}
+	for key, value := range extraLabels {
+		nodeLabels[key] = value
+	}
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}}
+}
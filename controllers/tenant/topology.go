@@ -0,0 +1,146 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// nodeTopology is the zone and region of a tenant cluster node.
+type nodeTopology struct {
+	zone   string
+	region string
+}
+
+// getNodeTopologies returns the distinct zone/region pairs of the nodes matching the selector, sorted by region
+// and zone. Nodes without any topology label are ignored.
+func getNodeTopologies(nodes []corev1.Node, topologyFromNodes *csiprovisionerv1alpha1.TopologyFromNodes) ([]nodeTopology, error) {
+	selector := labels.Everything()
+	if topologyFromNodes.NodeSelector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(topologyFromNodes.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector: %w", err)
+		}
+	}
+
+	seen := make(map[nodeTopology]bool)
+	var topologies []nodeTopology
+	for _, node := range nodes {
+		if !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		topology := nodeTopology{
+			zone:   node.Labels[corev1.LabelTopologyZone],
+			region: node.Labels[corev1.LabelTopologyRegion],
+		}
+		if topology == (nodeTopology{}) || seen[topology] {
+			continue
+		}
+		seen[topology] = true
+		topologies = append(topologies, topology)
+	}
+
+	sort.Slice(topologies, func(i, j int) bool {
+		if topologies[i].region != topologies[j].region {
+			return topologies[i].region < topologies[j].region
+		}
+		return topologies[i].zone < topologies[j].zone
+	})
+	return topologies, nil
+}
+
+// getNodeTopologySelectorTerms returns one topology term per zone/region pair, so that a volume is only
+// provisioned into a zone of the region the zone actually belongs to.
+func getNodeTopologySelectorTerms(topologies []nodeTopology) []corev1.TopologySelectorTerm {
+	var terms []corev1.TopologySelectorTerm
+	for _, topology := range topologies {
+		var requirements []corev1.TopologySelectorLabelRequirement
+		if topology.zone != "" {
+			requirements = append(requirements, corev1.TopologySelectorLabelRequirement{
+				Key:    corev1.LabelTopologyZone,
+				Values: []string{topology.zone},
+			})
+		}
+		if topology.region != "" {
+			requirements = append(requirements, corev1.TopologySelectorLabelRequirement{
+				Key:    corev1.LabelTopologyRegion,
+				Values: []string{topology.region},
+			})
+		}
+		terms = append(terms, corev1.TopologySelectorTerm{MatchLabelExpressions: requirements})
+	}
+	return terms
+}
+
+// getZoneRegions returns the regions of each zone, either from the configured zones and regions or from the
+// topologies discovered on the nodes.
+func getZoneRegions(storageClass csiprovisionerv1alpha1.StorageClass, nodes []corev1.Node) (map[string][]string, error) {
+	zoneRegions := make(map[string][]string)
+	if storageClass.TopologyFromNodes == nil {
+		for _, zone := range storageClass.Zones {
+			zoneRegions[zone] = storageClass.Regions
+		}
+		return zoneRegions, nil
+	}
+
+	topologies, err := getNodeTopologies(nodes, storageClass.TopologyFromNodes)
+	if err != nil {
+		return nil, err
+	}
+	for _, topology := range topologies {
+		if topology.zone == "" {
+			continue
+		}
+		regions := zoneRegions[topology.zone]
+		if topology.region != "" {
+			regions = append(regions, topology.region)
+		}
+		zoneRegions[topology.zone] = regions
+	}
+	return zoneRegions, nil
+}
+
+// nodeTopologyChanged filters node events that can change the topologies derived from nodes. Only the zone and region
+// labels are compared, changes of other labels are ignored.
+var nodeTopologyChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldLabels, newLabels := e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()
+		return oldLabels[corev1.LabelTopologyZone] != newLabels[corev1.LabelTopologyZone] ||
+			oldLabels[corev1.LabelTopologyRegion] != newLabels[corev1.LabelTopologyRegion]
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// enqueueTenant maps any event to the reconcile request of the tenant.
+func enqueueTenant(_ context.Context, _ client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: tenantName}}}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	infraVolumeAttributesClassNameParameterKey = "infraVolumeAttributesClassName"
+
+	reasonVolumeAttributesClassesReconciled = "Reconciled"
+	reasonVolumeAttributesClassesFailed     = "ReconcileFailed"
+	reasonVolumeAttributesClassesPending    = "RecreatePending"
+
+	// volumeAttributesClassRecreateInterval is the interval in which VolumeAttributesClasses waiting to be recreated
+	// are checked again.
+	volumeAttributesClassRecreateInterval = time.Minute
+)
+
+func getDesiredVolumeAttributesClass(obj metav1.Object, volumeAttributesClass csiprovisionerv1alpha1.VolumeAttributesClass) (*storagev1.VolumeAttributesClass, error) {
+	name, err := resolveName(volumeAttributesClass.Name, volumeAttributesClass.InfraVolumeAttributesClassName)
+	if err != nil {
+		return nil, fmt.Errorf("volume attributes class for infra volume attributes class %s: %w", volumeAttributesClass.InfraVolumeAttributesClassName, err)
+	}
+	if _, ok := volumeAttributesClass.Parameters[infraVolumeAttributesClassNameParameterKey]; ok {
+		return nil, fmt.Errorf("volume attributes class %s: parameters must not contain the reserved key %s", name, infraVolumeAttributesClassNameParameterKey)
+	}
+
+	parameters := maps.Clone(volumeAttributesClass.Parameters)
+	if parameters == nil {
+		parameters = make(map[string]string, 1)
+	}
+	parameters[infraVolumeAttributesClassNameParameterKey] = volumeAttributesClass.InfraVolumeAttributesClassName
+
+	return &storagev1.VolumeAttributesClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+		},
+		DriverName: provisioner,
+		Parameters: parameters,
+	}, nil
+}
+
+// getVolumeAttributesClassCondition returns the condition reporting the result of reconcileVolumeAttributesClasses.
+func getVolumeAttributesClassCondition(tenant *csiprovisionerv1alpha1.Tenant, pending []string, err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:               csiprovisionerv1alpha1.ConditionVolumeAttributesClassesReconciled,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: tenant.Generation,
+			Reason:             reasonVolumeAttributesClassesFailed,
+			Message:            err.Error(),
+		}
+	}
+	if len(pending) > 0 {
+		return metav1.Condition{
+			Type:               csiprovisionerv1alpha1.ConditionVolumeAttributesClassesReconciled,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: tenant.Generation,
+			Reason:             reasonVolumeAttributesClassesPending,
+			Message:            fmt.Sprintf("VolumeAttributesClasses %s are recreated once they are no longer used by any PVC", strings.Join(pending, ", ")),
+		}
+	}
+	return metav1.Condition{
+		Type:               csiprovisionerv1alpha1.ConditionVolumeAttributesClassesReconciled,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: tenant.Generation,
+		Reason:             reasonVolumeAttributesClassesReconciled,
+		Message:            fmt.Sprintf("%d VolumeAttributesClasses are up to date", len(tenant.Spec.VolumeAttributesClasses)),
+	}
+}
+
+// reconcileVolumeAttributesClasses creates the VolumeAttributesClasses of the tenant and deletes those created by the
+// operator that were removed from the tenant. The driver name and parameters of a VolumeAttributesClass are immutable,
+// so classes owned by the tenant are recreated if they changed. Classes in use are only deleted by the API server once
+// no PVC references them anymore, so they are skipped while they are being deleted and returned as pending.
+func (r *TenantReconciler) reconcileVolumeAttributesClasses(ctx context.Context, obj metav1.Object, common commonMetadata, volumeAttributesClasses []csiprovisionerv1alpha1.VolumeAttributesClass) ([]string, error) {
+	l := log.FromContext(ctx).WithName("volumeAttributesClass")
+	l.Info("Reconciling volumeAttributesClass")
+
+	var pending []string
+	names := make(map[string]bool, len(volumeAttributesClasses))
+	for _, volumeAttributesClass := range volumeAttributesClasses {
+		desiredVAC, err := getDesiredVolumeAttributesClass(obj, volumeAttributesClass)
+		if err != nil {
+			return nil, err
+		}
+		common.apply(desiredVAC)
+		if names[desiredVAC.Name] {
+			return nil, fmt.Errorf("duplicate volume attributes class name %s", desiredVAC.Name)
+		}
+		names[desiredVAC.Name] = true
+
+		existingVAC := &storagev1.VolumeAttributesClass{}
+		err = r.Client.Get(ctx, client.ObjectKeyFromObject(desiredVAC), existingVAC)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("failed to get VolumeAttributesClass %s: %w", desiredVAC.Name, err)
+		}
+		if err == nil {
+			if !metav1.IsControlledBy(existingVAC, obj) {
+				return nil, fmt.Errorf("volume attributes class %s is not managed by tenant %s", existingVAC.Name, obj.GetName())
+			}
+			if existingVAC.DeletionTimestamp != nil {
+				l.Info("VolumeAttributesClass is being deleted, skipping until it is no longer used by any PVC", "name", existingVAC.Name)
+				pending = append(pending, existingVAC.Name)
+				continue
+			}
+			if existingVAC.DriverName != desiredVAC.DriverName || !equality.Semantic.DeepEqual(existingVAC.Parameters, desiredVAC.Parameters) {
+				l.Info("Recreating VolumeAttributesClass to update immutable fields", "name", existingVAC.Name)
+				if err := r.Client.Delete(ctx, existingVAC, client.Preconditions{UID: &existingVAC.UID}); err != nil && !apierrors.IsNotFound(err) {
+					return nil, fmt.Errorf("failed to delete VolumeAttributesClass %s: %w", existingVAC.Name, err)
+				}
+				// The class is created again once the deletion completed, which is delayed while PVCs use it.
+				pending = append(pending, existingVAC.Name)
+				continue
+			}
+		}
+
+		l.Info("Applying VolumeAttributesClass", "name", desiredVAC.Name)
+		if err := r.apply(ctx, desiredVAC); err != nil {
+			return nil, fmt.Errorf("failed to apply VolumeAttributesClass %s: %w", desiredVAC.Name, err)
+		}
+	}
+
+	return pending, r.pruneVolumeAttributesClasses(ctx, obj, names)
+}
+
+// pruneVolumeAttributesClasses deletes the VolumeAttributesClasses owned by the tenant that are no longer desired.
+func (r *TenantReconciler) pruneVolumeAttributesClasses(ctx context.Context, obj metav1.Object, desiredNames map[string]bool) error {
+	volumeAttributesClasses := &storagev1.VolumeAttributesClassList{}
+	if err := r.Client.List(ctx, volumeAttributesClasses); err != nil {
+		return fmt.Errorf("failed to list volume attributes classes: %w", err)
+	}
+	for i := range volumeAttributesClasses.Items {
+		vac := &volumeAttributesClasses.Items[i]
+		if desiredNames[vac.Name] || !metav1.IsControlledBy(vac, obj) || vac.DeletionTimestamp != nil {
+			continue
+		}
+		log.FromContext(ctx).Info("Deleting removed volumeAttributesClass", "name", vac.Name)
+		if err := r.Client.Delete(ctx, vac); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete volume attributes class %s: %w", vac.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Reconcile volumeAttributesClass", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	Context("When volumeAttributesClass is reconciled", func() {
+		BeforeEach(func() {
+			testClient = fake.NewClientBuilder().WithObjects(&v1.VolumeAttributesClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "foreign"},
+				DriverName: provisioner,
+			}).Build()
+			testReconcile = &TenantReconciler{
+				Client: testClient,
+			}
+		})
+
+		It("should get created with the infra class as parameter", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeAttributesClasses = []v1alpha1.VolumeAttributesClass{{Name: "gold", InfraVolumeAttributesClassName: "ceph-gold", Parameters: map[string]string{"iops": "5000"}}}
+			Expect(testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)).Error().NotTo(HaveOccurred())
+			vac := v1.VolumeAttributesClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "gold"}, &vac)).NotTo(HaveOccurred())
+			Expect(vac.DriverName).Should(Equal(provisioner))
+			Expect(vac.Parameters).Should(Equal(map[string]string{"iops": "5000", infraVolumeAttributesClassNameParameterKey: "ceph-gold"}))
+		})
+
+		It("should recreate changed classes and prune removed ones", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeAttributesClasses = []v1alpha1.VolumeAttributesClass{{InfraVolumeAttributesClassName: "gold"}, {InfraVolumeAttributesClassName: "silver"}}
+			Expect(testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)).Error().NotTo(HaveOccurred())
+
+			testTenant.Spec.VolumeAttributesClasses = []v1alpha1.VolumeAttributesClass{{InfraVolumeAttributesClassName: "gold", Parameters: map[string]string{"iops": "5000"}}}
+			Expect(testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)).Should(Equal([]string{"kubevirt-gold"}))
+			Expect(testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)).Should(BeEmpty())
+			vacList := v1.VolumeAttributesClassList{}
+			Expect(testClient.List(context.TODO(), &vacList)).NotTo(HaveOccurred())
+			Expect(len(vacList.Items)).Should(Equal(2))
+			vac := v1.VolumeAttributesClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-gold"}, &vac)).NotTo(HaveOccurred())
+			Expect(vac.Parameters).Should(HaveKeyWithValue("iops", "5000"))
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "foreign"}, &vac)).NotTo(HaveOccurred())
+		})
+
+		It("should return an error for a class not managed by the tenant", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeAttributesClasses = []v1alpha1.VolumeAttributesClass{{Name: "foreign", InfraVolumeAttributesClassName: "gold"}}
+			pending, err := testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)
+			Expect(err).To(HaveOccurred())
+			Expect(getVolumeAttributesClassCondition(testTenant, pending, err).Status).Should(Equal(metav1.ConditionFalse))
+		})
+
+		It("should skip classes that are still in use while they are recreated", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeAttributesClasses = []v1alpha1.VolumeAttributesClass{{InfraVolumeAttributesClassName: "gold"}}
+			Expect(testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)).Error().NotTo(HaveOccurred())
+			vac := v1.VolumeAttributesClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-gold"}, &vac)).NotTo(HaveOccurred())
+			vac.Finalizers = []string{"kubernetes.io/vac-protection"}
+			Expect(testClient.Update(context.TODO(), &vac)).NotTo(HaveOccurred())
+
+			testTenant.Spec.VolumeAttributesClasses[0].Parameters = map[string]string{"iops": "5000"}
+			for range 2 {
+				pending, err := testReconcile.reconcileVolumeAttributesClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeAttributesClasses)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pending).Should(Equal([]string{"kubevirt-gold"}))
+				Expect(getVolumeAttributesClassCondition(testTenant, pending, err).Reason).Should(Equal(reasonVolumeAttributesClassesPending))
+			}
+		})
+	})
+})
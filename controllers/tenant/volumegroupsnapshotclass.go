@@ -0,0 +1,112 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	groupsnapshotv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const isDefaultVolumeGroupSnapshotClassAnnotationKey = "groupsnapshot.storage.kubernetes.io/is-default-class"
+
+func getDesiredVolumeGroupSnapshotClass(obj metav1.Object, volumeGroupSnapshotClass csiprovisionerv1alpha1.VolumeGroupSnapshotClass) (*groupsnapshotv1beta2.VolumeGroupSnapshotClass, error) {
+	name, err := resolveName(volumeGroupSnapshotClass.Name, volumeGroupSnapshotClass.InfraVolumeSnapshotClass)
+	if err != nil {
+		return nil, fmt.Errorf("volume group snapshot class for infra volume snapshot class %s: %w", volumeGroupSnapshotClass.InfraVolumeSnapshotClass, err)
+	}
+
+	deletionPolicy := snapshotv1.VolumeSnapshotContentDelete
+	if volumeGroupSnapshotClass.DeletionPolicy != "" {
+		deletionPolicy = snapshotv1.DeletionPolicy(volumeGroupSnapshotClass.DeletionPolicy)
+	}
+
+	return &groupsnapshotv1beta2.VolumeGroupSnapshotClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+			Annotations: map[string]string{
+				isDefaultVolumeGroupSnapshotClassAnnotationKey: strconv.FormatBool(volumeGroupSnapshotClass.IsDefaultClass != nil && *volumeGroupSnapshotClass.IsDefaultClass),
+			},
+		},
+		Driver: provisioner,
+		Parameters: map[string]string{
+			infraSnapshotClassNameParameterKey: volumeGroupSnapshotClass.InfraVolumeSnapshotClass,
+		},
+		DeletionPolicy: deletionPolicy,
+	}, nil
+}
+
+// volumeGroupSnapshotClassNeedsRecreate reports whether fields differ that the API server rejects to update.
+func volumeGroupSnapshotClassNeedsRecreate(current, desired *groupsnapshotv1beta2.VolumeGroupSnapshotClass) bool {
+	return current.Driver != desired.Driver ||
+		!equality.Semantic.DeepEqual(current.Parameters, desired.Parameters) ||
+		current.DeletionPolicy != desired.DeletionPolicy
+}
+
+func (r *TenantReconciler) reconcileVolumeGroupSnapshotClasses(ctx context.Context, obj metav1.Object, common commonMetadata, volumeGroupSnapshotClasses []csiprovisionerv1alpha1.VolumeGroupSnapshotClass) error {
+	l := log.FromContext(ctx).WithName("volumeGroupSnapshotClass")
+	l.Info("Reconciling volumeGroupSnapshotClass")
+	names := make(map[string]bool, len(volumeGroupSnapshotClasses))
+	for _, volumeGroupSnapshotClass := range volumeGroupSnapshotClasses {
+		desiredVGSC, err := getDesiredVolumeGroupSnapshotClass(obj, volumeGroupSnapshotClass)
+		if err != nil {
+			return err
+		}
+		common.apply(desiredVGSC)
+		if names[desiredVGSC.Name] {
+			return fmt.Errorf("duplicate volume group snapshot class name %s", desiredVGSC.Name)
+		}
+		names[desiredVGSC.Name] = true
+
+		existingVGSC := &groupsnapshotv1beta2.VolumeGroupSnapshotClass{}
+		err = r.Client.Get(ctx, client.ObjectKeyFromObject(desiredVGSC), existingVGSC)
+		notFound := apierrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return fmt.Errorf("failed to get VolumeGroupSnapshotClass %s: %w", desiredVGSC.Name, err)
+		}
+		if !notFound && volumeGroupSnapshotClassNeedsRecreate(existingVGSC, desiredVGSC) {
+			if !metav1.IsControlledBy(existingVGSC, obj) {
+				return fmt.Errorf("volume group snapshot class %s is not managed by tenant %s and its immutable fields differ", existingVGSC.Name, obj.GetName())
+			}
+			l.Info("Recreating VolumeGroupSnapshotClass to update immutable fields", "name", existingVGSC.Name)
+			if err := r.Client.Delete(ctx, existingVGSC, client.Preconditions{UID: &existingVGSC.UID}); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete VolumeGroupSnapshotClass %s: %w", existingVGSC.Name, err)
+			}
+		}
+
+		l.Info("Applying VolumeGroupSnapshotClass", "name", desiredVGSC.Name)
+		if err := r.apply(ctx, desiredVGSC); err != nil {
+			return fmt.Errorf("failed to apply VolumeGroupSnapshotClass %s: %w", desiredVGSC.Name, err)
+		}
+	}
+
+	return nil
+}
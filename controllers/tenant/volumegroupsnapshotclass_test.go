@@ -0,0 +1,70 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	groupsnapshotv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Reconcile volumeGroupSnapshotClass", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	Context("When volumeGroupSnapshotClass is reconciled", func() {
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(groupsnapshotv1beta2.AddToScheme(testScheme)).NotTo(HaveOccurred())
+			testClient = fake.NewClientBuilder().WithScheme(testScheme).Build()
+			testReconcile = &TenantReconciler{
+				Client: testClient,
+			}
+		})
+
+		It("should get created", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeGroupSnapshotClasses = []v1alpha1.VolumeGroupSnapshotClass{{InfraVolumeSnapshotClass: "ceph", IsDefaultClass: ptr.To(true)}}
+			Expect(testReconcile.reconcileVolumeGroupSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeGroupSnapshotClasses)).NotTo(HaveOccurred())
+			vgsc := groupsnapshotv1beta2.VolumeGroupSnapshotClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &vgsc)).NotTo(HaveOccurred())
+			Expect(vgsc.Driver).Should(Equal(provisioner))
+			Expect(vgsc.DeletionPolicy).Should(Equal(snapshotv1.VolumeSnapshotContentDelete))
+			Expect(vgsc.Annotations).Should(HaveKeyWithValue(isDefaultVolumeGroupSnapshotClassAnnotationKey, "true"))
+		})
+
+		It("should be recreated if the deletion policy changes", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeGroupSnapshotClasses = []v1alpha1.VolumeGroupSnapshotClass{{InfraVolumeSnapshotClass: "ceph"}}
+			Expect(testReconcile.reconcileVolumeGroupSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeGroupSnapshotClasses)).NotTo(HaveOccurred())
+
+			testTenant.Spec.VolumeGroupSnapshotClasses[0].DeletionPolicy = string(snapshotv1.VolumeSnapshotContentRetain)
+			Expect(testReconcile.reconcileVolumeGroupSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeGroupSnapshotClasses)).NotTo(HaveOccurred())
+			vgsc := groupsnapshotv1beta2.VolumeGroupSnapshotClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &vgsc)).NotTo(HaveOccurred())
+			Expect(vgsc.DeletionPolicy).Should(Equal(snapshotv1.VolumeSnapshotContentRetain))
+		})
+	})
+})
@@ -19,65 +19,84 @@ package tenant
 import (
 	"context"
 	"fmt"
+	"maps"
 	"strconv"
 
 	csiprovisionerv1alpha1 "github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-const isDefaultVolumeSnapshotClassAnnotationKey = "snapshot.storage.kubernetes.io/is-default-class"
+const (
+	isDefaultVolumeSnapshotClassAnnotationKey = "snapshot.storage.kubernetes.io/is-default-class"
+	infraSnapshotClassNameParameterKey        = "infraSnapshotClassName"
+)
+
+func getDesiredVolumeSnapshotClass(obj metav1.Object, volumeSnapshotClass csiprovisionerv1alpha1.VolumeSnapshotClass) (*snapshotv1.VolumeSnapshotClass, error) {
+	name, err := resolveName(volumeSnapshotClass.Name, volumeSnapshotClass.InfraVolumeSnapshotClass)
+	if err != nil {
+		return nil, fmt.Errorf("volume snapshot class for infra volume snapshot class %s: %w", volumeSnapshotClass.InfraVolumeSnapshotClass, err)
+	}
+	if _, ok := volumeSnapshotClass.Parameters[infraSnapshotClassNameParameterKey]; ok {
+		return nil, fmt.Errorf("volume snapshot class %s: parameters must not contain the reserved key %s", name, infraSnapshotClassNameParameterKey)
+	}
+	if _, ok := volumeSnapshotClass.Annotations[isDefaultVolumeSnapshotClassAnnotationKey]; ok {
+		return nil, fmt.Errorf("volume snapshot class %s: annotations must not contain the reserved key %s", name, isDefaultVolumeSnapshotClassAnnotationKey)
+	}
+
+	deletionPolicy := snapshotv1.VolumeSnapshotContentDelete
+	if volumeSnapshotClass.DeletionPolicy != "" {
+		deletionPolicy = snapshotv1.DeletionPolicy(volumeSnapshotClass.DeletionPolicy)
+	}
+
+	annotations := maps.Clone(volumeSnapshotClass.Annotations)
+	if annotations == nil {
+		annotations = make(map[string]string, 1)
+	}
+	annotations[isDefaultVolumeSnapshotClassAnnotationKey] = strconv.FormatBool(volumeSnapshotClass.IsDefaultClass != nil && *volumeSnapshotClass.IsDefaultClass)
 
-func (r *TenantReconciler) reconcileVolumeSnapshotClasses(ctx context.Context, obj metav1.Object, volumeSnapshotClasses []csiprovisionerv1alpha1.VolumeSnapshotClass) error {
+	parameters := maps.Clone(volumeSnapshotClass.Parameters)
+	if parameters == nil {
+		parameters = make(map[string]string, 1)
+	}
+	parameters[infraSnapshotClassNameParameterKey] = volumeSnapshotClass.InfraVolumeSnapshotClass
+
+	return &snapshotv1.VolumeSnapshotClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
+			},
+			Labels:      maps.Clone(volumeSnapshotClass.Labels),
+			Annotations: annotations,
+		},
+		Driver:         provisioner,
+		Parameters:     parameters,
+		DeletionPolicy: deletionPolicy,
+	}, nil
+}
+
+func (r *TenantReconciler) reconcileVolumeSnapshotClasses(ctx context.Context, obj metav1.Object, common commonMetadata, volumeSnapshotClasses []csiprovisionerv1alpha1.VolumeSnapshotClass) error {
 	l := log.FromContext(ctx).WithName("volumeSnapshotClass")
 	l.Info("Reconciling volumeSnapshotClass")
+	names := make(map[string]bool, len(volumeSnapshotClasses))
 	for _, volumeSnapshotClass := range volumeSnapshotClasses {
-		deletionPolicy := snapshotv1.VolumeSnapshotContentDelete
-		if volumeSnapshotClass.DeletionPolicy != "" {
-			deletionPolicy = snapshotv1.DeletionPolicy(volumeSnapshotClass.DeletionPolicy)
+		desiredVSC, err := getDesiredVolumeSnapshotClass(obj, volumeSnapshotClass)
+		if err != nil {
+			return err
 		}
-
-		desiredVSC := &snapshotv1.VolumeSnapshotClass{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: fmt.Sprintf("kubevirt-%s", volumeSnapshotClass.InfraVolumeSnapshotClass),
-				OwnerReferences: []metav1.OwnerReference{
-					*metav1.NewControllerRef(obj, csiprovisionerv1alpha1.GroupVersion.WithKind("Tenant")),
-				},
-				Annotations: map[string]string{
-					isDefaultVolumeSnapshotClassAnnotationKey: strconv.FormatBool(volumeSnapshotClass.IsDefaultClass != nil && *volumeSnapshotClass.IsDefaultClass),
-				},
-			},
-			Driver: provisioner,
-			Parameters: map[string]string{
-				"infraSnapshotClassName": volumeSnapshotClass.InfraVolumeSnapshotClass,
-			},
-			DeletionPolicy: deletionPolicy,
+		common.apply(desiredVSC)
+		if names[desiredVSC.Name] {
+			return fmt.Errorf("duplicate volume snapshot class name %s", desiredVSC.Name)
 		}
+		names[desiredVSC.Name] = true
 
-		existingVSC := &snapshotv1.VolumeSnapshotClass{}
-		err := r.Client.Get(ctx, types.NamespacedName{Name: desiredVSC.Name}, existingVSC)
-		if apierrors.IsNotFound(err) {
-			l.Info("Creating VolumeSnapshotClass", "name", desiredVSC.Name)
-			if err := r.Client.Create(ctx, desiredVSC); err != nil {
-				return fmt.Errorf("failed to create VolumeSnapshotClass %s: %w", desiredVSC.Name, err)
-			}
-		} else if err != nil {
-			return fmt.Errorf("failed to get VolumeSnapshotClass %s: %w", desiredVSC.Name, err)
-		} else {
-			existingVSC.Annotations = desiredVSC.Annotations
-			existingVSC.OwnerReferences = desiredVSC.OwnerReferences
-			existingVSC.Parameters = desiredVSC.Parameters
-			existingVSC.DeletionPolicy = desiredVSC.DeletionPolicy
-			existingVSC.Driver = desiredVSC.Driver
-			l.Info("Updating VolumeSnapshotClass", "name", desiredVSC.Name)
-			if err := r.Client.Update(ctx, existingVSC); err != nil {
-				return fmt.Errorf("failed to update VolumeSnapshotClass %s: %w", desiredVSC.Name, err)
-			}
+		l.Info("Applying VolumeSnapshotClass", "name", desiredVSC.Name)
+		if err := r.apply(ctx, desiredVSC); err != nil {
+			return fmt.Errorf("failed to apply VolumeSnapshotClass %s: %w", desiredVSC.Name, err)
 		}
 	}
 
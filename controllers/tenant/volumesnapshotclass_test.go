@@ -0,0 +1,103 @@
+/*
+Copyright 2026 The KubeVirt CSI driver Operator Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenant
+
+import (
+	"context"
+
+	"github.com/kubermatic/kubevirt-csi-driver-operator/api/v1alpha1"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Reconcile volumeSnapshotClass", func() {
+	var testReconcile *TenantReconciler
+	var testClient client.Client
+	Context("When volumeSnapshotClass is reconciled", func() {
+		BeforeEach(func() {
+			testScheme := runtime.NewScheme()
+			Expect(snapshotv1.AddToScheme(testScheme)).NotTo(HaveOccurred())
+			testClient = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(&snapshotv1.VolumeSnapshotClass{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "kubevirt-ceph",
+					Labels:      map[string]string{"backup.example.com/enabled": "true"},
+					Annotations: map[string]string{"backup.example.com/policy": "daily"},
+				},
+				Driver:         provisioner,
+				DeletionPolicy: snapshotv1.VolumeSnapshotContentDelete,
+			}).Build()
+			testReconcile = &TenantReconciler{
+				Client:   testClient,
+				Recorder: events.NewFakeRecorder(10),
+			}
+		})
+
+		It("should merge labels and annotations and set the parameters", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeSnapshotClasses = []v1alpha1.VolumeSnapshotClass{{
+				InfraVolumeSnapshotClass: "ceph",
+				Labels:                   map[string]string{"team": "storage"},
+				Annotations:              map[string]string{"description": "ceph snapshots"},
+				Parameters:               map[string]string{"incremental": "true"},
+			}}
+			Expect(testReconcile.reconcileVolumeSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeSnapshotClasses)).NotTo(HaveOccurred())
+			vsc := snapshotv1.VolumeSnapshotClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &vsc)).NotTo(HaveOccurred())
+			Expect(vsc.Labels).Should(Equal(map[string]string{"backup.example.com/enabled": "true", "team": "storage"}))
+			Expect(vsc.Annotations).Should(HaveKeyWithValue("backup.example.com/policy", "daily"))
+			Expect(vsc.Annotations).Should(HaveKeyWithValue("description", "ceph snapshots"))
+			Expect(vsc.Annotations).Should(HaveKeyWithValue(isDefaultVolumeSnapshotClassAnnotationKey, "false"))
+			Expect(vsc.Annotations).Should(HaveKey(desiredStateHashAnnotationKey))
+			Expect(vsc.Parameters).Should(Equal(map[string]string{"incremental": "true", infraSnapshotClassNameParameterKey: "ceph"}))
+		})
+
+		It("should skip the write if the desired state didn't change", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeSnapshotClasses = []v1alpha1.VolumeSnapshotClass{{InfraVolumeSnapshotClass: "ceph"}}
+			Expect(testReconcile.reconcileVolumeSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeSnapshotClasses)).NotTo(HaveOccurred())
+			vsc := snapshotv1.VolumeSnapshotClass{}
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &vsc)).NotTo(HaveOccurred())
+			resourceVersion := vsc.ResourceVersion
+
+			Expect(testReconcile.reconcileVolumeSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeSnapshotClasses)).NotTo(HaveOccurred())
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &vsc)).NotTo(HaveOccurred())
+			Expect(vsc.ResourceVersion).Should(Equal(resourceVersion))
+
+			testTenant.Spec.VolumeSnapshotClasses[0].DeletionPolicy = string(snapshotv1.VolumeSnapshotContentRetain)
+			Expect(testReconcile.reconcileVolumeSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeSnapshotClasses)).NotTo(HaveOccurred())
+			Expect(testClient.Get(context.TODO(), client.ObjectKey{Name: "kubevirt-ceph"}, &vsc)).NotTo(HaveOccurred())
+			Expect(vsc.ResourceVersion).ShouldNot(Equal(resourceVersion))
+			Expect(vsc.DeletionPolicy).Should(Equal(snapshotv1.VolumeSnapshotContentRetain))
+		})
+
+		It("should return an error for the reserved parameter key", func() {
+			testTenant := createTestTenant(nil)
+			testTenant.Spec.VolumeSnapshotClasses = []v1alpha1.VolumeSnapshotClass{{
+				InfraVolumeSnapshotClass: "ceph",
+				Parameters:               map[string]string{infraSnapshotClassNameParameterKey: "other"},
+			}}
+			Expect(testReconcile.reconcileVolumeSnapshotClasses(context.TODO(), testTenant.GetObjectMeta(), commonMetadata{}, testTenant.Spec.VolumeSnapshotClasses)).To(HaveOccurred())
+		})
+	})
+})
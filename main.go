@@ -17,20 +17,28 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/kubermatic/kubevirt-csi-driver-operator/controllers/persistentvolumeclaims"
+	groupsnapshotv1beta2 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumegroupsnapshot/v1beta2"
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -50,19 +58,29 @@ func init() {
 
 	utilruntime.Must(csiprovisionerv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(snapshotv1.AddToScheme(scheme))
+	utilruntime.Must(groupsnapshotv1beta2.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(os.Args[2:]))
+	}
+
 	var (
 		metricsAddr          string
 		enableLeaderElection bool
 		probeAddr            string
 		overwriteRegistry    string
+		topologyKeys         string
 	)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&overwriteRegistry, "overwrite-registry", "", "registry to use for all images")
+	flag.StringVar(&topologyKeys, "pv-topology-keys", strings.Join(persistentvolumeclaims.DefaultTopologyKeys, ","),
+		"Comma separated node labels copied into the node affinity of PVs. "+
+			"StorageClasses with allowedTopologies use the keys of their allowed topologies instead.")
 
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
@@ -90,6 +108,8 @@ func main() {
 	if err = (&tenant.TenantReconciler{
 		Client:            mgr.GetClient(),
 		Scheme:            mgr.GetScheme(),
+		Recorder:          mgr.GetEventRecorder("tenant-controller"),
+		Discovery:         discovery.NewDiscoveryClientForConfigOrDie(mgr.GetConfig()),
 		OverwriteRegistry: overwriteRegistry,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Tenant")
@@ -97,7 +117,9 @@ func main() {
 	}
 
 	if err = (&persistentvolumeclaims.Reconciler{
-		Client: mgr.GetClient(),
+		Client:       mgr.GetClient(),
+		Recorder:     mgr.GetEventRecorder(persistentvolumeclaims.ControllerName),
+		TopologyKeys: parseTopologyKeys(topologyKeys),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", persistentvolumeclaims.ControllerName)
 		os.Exit(1)
@@ -120,3 +142,88 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// parseTopologyKeys splits the comma separated topology keys, ignoring empty entries.
+func parseTopologyKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// runAudit reports the node affinity of all PVs provisioned by csi.kubevirt.io and, unless it's a dry run, adds the
+// missing requirements. It returns the exit code.
+func runAudit(args []string) int {
+	var (
+		dryRun       bool
+		topologyKeys string
+	)
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	flags.BoolVar(&dryRun, "dry-run", true, "Only report the node affinity of PVs, without adding missing requirements.")
+	flags.StringVar(&topologyKeys, "pv-topology-keys", strings.Join(persistentvolumeclaims.DefaultTopologyKeys, ","),
+		"Comma separated node labels copied into the node affinity of PVs.")
+	// ctrl.GetConfigOrDie reads the kubeconfig flag, which is only registered on the default command line.
+	config.RegisterFlags(flags)
+	opts := zap.Options{}
+	opts.BindFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	// The reconciler relies on field indexes, so it reads from the cache of a manager without any controllers.
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to create manager")
+		return 1
+	}
+	ctx, cancel := context.WithCancel(ctrl.SetupSignalHandler())
+	defer cancel()
+	if err := persistentvolumeclaims.IndexFields(ctx, mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up field indexes")
+		return 1
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := mgr.Start(ctx); err != nil {
+			setupLog.Error(err, "problem running manager")
+		}
+	}()
+	// The manager shuts down its event broadcaster when it stops, so waiting for it flushes the recorded events.
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	if !mgr.GetCache().WaitForCacheSync(ctx) {
+		setupLog.Error(nil, "unable to sync caches")
+		return 1
+	}
+
+	results, err := (&persistentvolumeclaims.Reconciler{
+		Client:       mgr.GetClient(),
+		Recorder:     mgr.GetEventRecorder(persistentvolumeclaims.ControllerName),
+		TopologyKeys: parseTopologyKeys(topologyKeys),
+	}).Audit(ctx, dryRun)
+	if err != nil {
+		setupLog.Error(err, "audit failed")
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PV\tPVC\tSTATUS\tMESSAGE")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.PersistentVolume, result.PersistentVolumeClaim, result.Status, result.Message)
+	}
+	if err := w.Flush(); err != nil {
+		setupLog.Error(err, "unable to print audit report")
+		return 1
+	}
+	return 0
+}